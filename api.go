@@ -1,26 +1,113 @@
 package jmespath
 
-import "strconv"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
 
 // JMESPath is the representation of a compiled JMES path query. A JMESPath is
 // safe for concurrent use by multiple goroutines.
 type JMESPath struct {
-	ast  ASTNode
-	intr *treeInterpreter
+	ast ASTNode
+	// parsed is the AST before it was optimized.
+	parsed  ASTNode
+	program *program
+	// mask is the part of a JSON document decoded by SearchJSON.
+	mask   *fieldMask
+	intr   *treeInterpreter
+	limits Limits
+}
+
+// Runtime holds the set of functions available to the expressions it
+// compiles. Use a Runtime to make your own functions available alongside
+// the builtin JMESPath functions.
+type Runtime struct {
+	fCall *functionCaller
+}
+
+// NewRuntime creates a Runtime that knows about the builtin JMESPath functions.
+func NewRuntime() *Runtime {
+	return &Runtime{fCall: newFunctionCaller()}
+}
+
+// RegisterFunction makes a function available to expressions compiled by the
+// runtime. A function with the same name, builtin or not, is replaced.
+// Expressions compiled before the call keep the functions they were compiled
+// with, but the caches of the runtime compile them again. RegisterFunction
+// must not be called concurrently with other methods of the same Runtime,
+// or of its caches.
+func (r *Runtime) RegisterFunction(entry FunctionEntry) error {
+	if entry.Name == "" {
+		return errors.New("function name must not be empty")
+	}
+	if entry.Handler == nil {
+		return fmt.Errorf("function %s has no handler", entry.Name)
+	}
+	for i, spec := range entry.Arguments {
+		if len(spec.Types) == 0 {
+			return fmt.Errorf("argument %d of function %s accepts no types", i, entry.Name)
+		}
+		if spec.Variadic && i != len(entry.Arguments)-1 {
+			return fmt.Errorf("only the last argument of function %s can be variadic", entry.Name)
+		}
+		if spec.Optional && !spec.Variadic {
+			return fmt.Errorf("argument %d of function %s is optional but not variadic", i, entry.Name)
+		}
+	}
+	r.fCall = r.fCall.with(entry)
+	return nil
 }
 
 // Compile parses a JMESPath expression and returns, if successful, a JMESPath
-// object that can be used to match against data.
-func Compile(expression string) (*JMESPath, error) {
+// object that can be used to match against data using the runtime's functions.
+// Calls to unknown functions, or with the wrong number of arguments, and
+// variables not bound by an enclosing let expression are reported as a
+// SyntaxError. The expression is optimized before it is compiled: parts of
+// it that don't depend on the data are evaluated once and for all, and a
+// filter piped into [0] stops at the first match.
+//
+// The parts evaluated by Compile aren't evaluated again by searches, so the
+// context given to SearchContext doesn't apply to them. Their evaluation is
+// bounded, parts too costly to evaluate are left to the searches. Limits
+// given to WithLimits apply to every part of the expression, see there.
+func (r *Runtime) Compile(expression string) (*JMESPath, error) {
 	parser := NewParser()
-	ast, err := parser.Parse(expression)
+	parsed, err := parser.Parse(expression)
 	if err != nil {
 		return nil, err
 	}
-	jmespath := &JMESPath{ast: ast, intr: newInterpreter()}
+	if err := r.fCall.validate(parsed, expression); err != nil {
+		return nil, err
+	}
+	ast := optimize(parsed, r.fCall, true)
+	jmespath := &JMESPath{
+		ast:     ast,
+		parsed:  parsed,
+		program: compile(ast, false),
+		mask:    fieldMaskOf(ast),
+		intr:    &treeInterpreter{fCall: r.fCall},
+	}
 	return jmespath, nil
 }
 
+// Search evaluates a JMESPath expression against input data using the
+// runtime's functions and returns the result.
+func (r *Runtime) Search(expression string, data interface{}) (interface{}, error) {
+	jmespath, err := r.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return jmespath.Search(data)
+}
+
+// Compile parses a JMESPath expression and returns, if successful, a JMESPath
+// object that can be used to match against data.
+func Compile(expression string) (*JMESPath, error) {
+	return NewRuntime().Compile(expression)
+}
+
 // MustCompile is like Compile but panics if the expression cannot be parsed.
 // It simplifies safe initialization of global variables holding compiled
 // JMESPaths.
@@ -32,18 +119,75 @@ func MustCompile(expression string) *JMESPath {
 	return jmespath
 }
 
+// AST returns the abstract syntax tree of the compiled expression. The
+// returned tree is a copy, changing it does not affect the JMESPath.
+func (jp *JMESPath) AST() ASTNode {
+	return jp.parsed.copy()
+}
+
+// OptimizedAST returns the abstract syntax tree that is evaluated by
+// searches, as optimized by Compile. Its constant parts may have been
+// replaced by literals, and it may hold node types that the parser never
+// produces, such as ASTFirstMatch. The returned tree is a copy, changing it
+// does not affect the JMESPath.
+func (jp *JMESPath) OptimizedAST() ASTNode {
+	return jp.ast.copy()
+}
+
+// WithLimits returns a copy of the JMESPath that enforces limits whenever it
+// is evaluated. An evaluation exceeding one of the limits fails with the
+// corresponding error, e.g. a *StepLimitError for Limits.MaxSteps.
+//
+// The parts of the expression that don't depend on the data, which Compile
+// evaluates once and for all, are evaluated by each search instead, so
+// that they count towards the limits.
+func (jp *JMESPath) WithLimits(limits Limits) *JMESPath {
+	limited := *jp
+	limited.limits = limits
+	unlimited := limits == (Limits{})
+	limited.ast = optimize(jp.parsed, jp.intr.fCall, unlimited)
+	limited.program = compile(limited.ast, !unlimited)
+	limited.mask = fieldMaskOf(limited.ast)
+	return &limited
+}
+
 // Search evaluates a JMESPath expression against input data and returns the result.
 func (jp *JMESPath) Search(data interface{}) (interface{}, error) {
-	return jp.intr.Execute(jp.ast, data)
+	return jp.evaluate(jp.newEvaluation(), data)
 }
 
-// Search evaluates a JMESPath expression against input data and returns the result.
-func Search(expression string, data interface{}) (interface{}, error) {
-	intr := newInterpreter()
-	parser := NewParser()
-	ast, err := parser.Parse(expression)
+// SearchContext is like Search but stops the evaluation once ctx is done.
+// The error returned in that case is a *CanceledError wrapping ctx.Err().
+func (jp *JMESPath) SearchContext(ctx context.Context, data interface{}) (interface{}, error) {
+	intr := jp.newEvaluation()
+	intr.ctx = ctx
+	return jp.evaluate(intr, data)
+}
+
+// newEvaluation returns an interpreter for a single evaluation. The
+// interpreter keeps track of the state of the evaluation, such as the
+// variables in scope, so it can't be shared.
+func (jp *JMESPath) newEvaluation() *treeInterpreter {
+	return &treeInterpreter{fCall: jp.intr.fCall, limits: jp.limits}
+}
+
+func (jp *JMESPath) evaluate(intr *treeInterpreter, data interface{}) (interface{}, error) {
+	intr.root = data
+	m := vm{intr: intr}
+	result, err := m.run(jp.program, data)
 	if err != nil {
 		return nil, err
 	}
-	return intr.Execute(ast, data)
+	if err := intr.checkResult(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Search evaluates a JMESPath expression against input data and returns the result.
+// The compiled expression is kept in DefaultCache, so searching with the same
+// expression again doesn't compile it again. The other package level
+// functions taking an expression share the same cache.
+func Search(expression string, data interface{}) (interface{}, error) {
+	return defaultCache.Search(expression, data)
 }
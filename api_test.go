@@ -1,8 +1,11 @@
 package jmespath
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/jmespath/go-jmespath/internal/testify/assert"
 )
@@ -42,3 +45,224 @@ func TestInvalidMustCompilePanics(t *testing.T) {
 	}()
 	MustCompile("not a valid expression")
 }
+
+func TestCompileValidatesFunctionCalls(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		expression string
+		offset     int
+		unknown    bool
+	}{
+		{"nosuch_fn(@)", 0, true},
+		{"foo[?nosuch_fn(@)]", 5, true},
+		{"abs(a, b)", 0, false},
+		{"a | not_null()", 4, false},
+		{"sort_by(@, &abs())", 12, false},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.expression)
+		var syntaxError SyntaxError
+		if !assert.True(errors.As(err, &syntaxError), tt.expression) {
+			continue
+		}
+		assert.Equal(tt.offset, syntaxError.Offset, tt.expression)
+		var unknownErr *UnknownFunctionError
+		var arityErr *InvalidArityError
+		if tt.unknown {
+			assert.True(errors.As(err, &unknownErr), tt.expression)
+		} else {
+			assert.True(errors.As(err, &arityErr), tt.expression)
+		}
+	}
+}
+
+func TestRuntimeCompileUsesRegisteredFunctions(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	_, err := runtime.Compile("custom(@)")
+	assert.NotNil(err)
+	err = runtime.RegisterFunc("custom", func(s string) string { return s })
+	assert.Nil(err)
+	_, err = runtime.Compile("custom(@)")
+	assert.Nil(err)
+	_, err = runtime.Compile("custom(@, @)")
+	assert.NotNil(err)
+}
+
+func TestRuntimeCustomFunction(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	err := runtime.RegisterFunction(FunctionEntry{
+		Name: "double",
+		Arguments: []ArgSpec{
+			{Types: []JpType{JpNumber}},
+		},
+		Handler: func(arguments []interface{}) (interface{}, error) {
+			return arguments[0].(float64) * 2, nil
+		},
+	})
+	assert.Nil(err)
+	data := map[string]interface{}{"foo": 21.0}
+	result, err := runtime.Search("double(foo)", data)
+	assert.Nil(err)
+	assert.Equal(42.0, result)
+
+	_, err = runtime.Search("double('bar')", data)
+	assert.NotNil(err)
+	_, err = Search("double(foo)", data)
+	assert.NotNil(err)
+}
+
+func TestRuntimeCustomVariadicFunction(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	err := runtime.RegisterFunction(FunctionEntry{
+		Name: "concat",
+		Arguments: []ArgSpec{
+			{Types: []JpType{JpString}, Variadic: true},
+		},
+		Handler: func(arguments []interface{}) (interface{}, error) {
+			result := ""
+			for _, arg := range arguments {
+				result += arg.(string)
+			}
+			return result, nil
+		},
+	})
+	assert.Nil(err)
+	precompiled, err := runtime.Compile("concat(a, b, c)")
+	assert.Nil(err)
+	result, err := precompiled.Search(map[string]interface{}{"a": "x", "b": "y", "c": "z"})
+	assert.Nil(err)
+	assert.Equal("xyz", result)
+	_, err = precompiled.Search(map[string]interface{}{"a": "x", "b": 1.0, "c": "z"})
+	assert.NotNil(err)
+}
+
+func TestRuntimeCustomExpRefFunction(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	err := runtime.RegisterFunction(FunctionEntry{
+		Name: "count_by",
+		Arguments: []ArgSpec{
+			{Types: []JpType{JpArray}},
+			{Types: []JpType{JpExpref}},
+		},
+		Handler: func(arguments []interface{}) (interface{}, error) {
+			ref := arguments[1].(ExpRef)
+			counts := map[string]interface{}{}
+			for _, item := range arguments[0].([]interface{}) {
+				key, err := ref.Evaluate(item)
+				if err != nil {
+					return nil, err
+				}
+				k, _ := key.(string)
+				n, _ := counts[k].(float64)
+				counts[k] = n + 1
+			}
+			return counts, nil
+		},
+	})
+	assert.Nil(err)
+	var data interface{}
+	err = json.Unmarshal([]byte(`[{"t": "a"}, {"t": "b"}, {"t": "a"}]`), &data)
+	assert.Nil(err)
+	result, err := runtime.Search("count_by(@, &t)", data)
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"a": 2.0, "b": 1.0}, result)
+}
+
+func TestRuntimeReplacingFunctionKeepsCompiledExpressions(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	before, err := runtime.Compile("abs(@)")
+	assert.Nil(err)
+	err = runtime.RegisterFunction(FunctionEntry{
+		Name:      "abs",
+		Arguments: []ArgSpec{{Types: []JpType{JpAny}}},
+		Handler: func(arguments []interface{}) (interface{}, error) {
+			return "replaced", nil
+		},
+	})
+	assert.Nil(err)
+	result, err := before.Search(-1.0)
+	assert.Nil(err)
+	assert.Equal(1.0, result)
+	result, err = runtime.Search("abs(@)", -1.0)
+	assert.Nil(err)
+	assert.Equal("replaced", result)
+}
+
+func TestRuntimeRegisterFunctionErrors(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	handler := func(arguments []interface{}) (interface{}, error) { return nil, nil }
+	assert.NotNil(runtime.RegisterFunction(FunctionEntry{Handler: handler}))
+	assert.NotNil(runtime.RegisterFunction(FunctionEntry{Name: "f"}))
+	assert.NotNil(runtime.RegisterFunction(FunctionEntry{
+		Name:      "f",
+		Arguments: []ArgSpec{{}},
+		Handler:   handler,
+	}))
+	assert.NotNil(runtime.RegisterFunction(FunctionEntry{
+		Name: "f",
+		Arguments: []ArgSpec{
+			{Types: []JpType{JpAny}, Variadic: true},
+			{Types: []JpType{JpAny}},
+		},
+		Handler: handler,
+	}))
+	assert.NotNil(runtime.RegisterFunction(FunctionEntry{
+		Name:      "f",
+		Arguments: []ArgSpec{{Types: []JpType{JpAny}, Optional: true}},
+		Handler:   handler,
+	}))
+}
+
+func TestSearchContext(t *testing.T) {
+	assert := assert.New(t)
+	precompiled, err := Compile("foo[*].bar")
+	assert.Nil(err)
+	data := map[string]interface{}{
+		"foo": []interface{}{
+			map[string]interface{}{"bar": 1.0},
+			map[string]interface{}{"bar": 2.0},
+		},
+	}
+	result, err := precompiled.SearchContext(context.Background(), data)
+	assert.Nil(err)
+	assert.Equal([]interface{}{1.0, 2.0}, result)
+}
+
+func TestSearchContextCanceled(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var data interface{}
+	err := json.Unmarshal([]byte(`{"foo": [[3, 1], [2]], "bar": {"a": 1}}`), &data)
+	assert.Nil(err)
+	expressions := []string{
+		"foo[*]",
+		"foo[?@]",
+		"foo[]",
+		"bar.*",
+		"map(&@, foo)",
+		"max_by(foo, &length(@))",
+		"sort_by(foo, &length(@))",
+	}
+	for _, expression := range expressions {
+		_, err = MustCompile(expression).SearchContext(ctx, data)
+		var canceled *CanceledError
+		if assert.True(errors.As(err, &canceled), expression) {
+			assert.True(errors.Is(err, context.Canceled), expression)
+		}
+	}
+}
+
+func TestSearchContextDeadline(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	_, err := MustCompile("[*]").SearchContext(ctx, []interface{}{1.0})
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+}
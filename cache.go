@@ -0,0 +1,156 @@
+package jmespath
+
+import (
+	"container/list"
+	"sync"
+)
+
+var defaultCache = NewCache(256)
+
+// DefaultCache returns the cache of compiled expressions used by Search and
+// the other package level functions. Use its Resize method to change its
+// capacity, or to disable it.
+func DefaultCache() *Cache {
+	return defaultCache
+}
+
+// Cache keeps the most recently used compiled expressions, so that
+// searching with the same expression again doesn't parse it again. When
+// the cache is full, the least recently used expression is evicted. A
+// Cache is safe for concurrent use by multiple goroutines. The caches
+// created by NewCache compile expressions with the builtin functions, the
+// ones created by Runtime.NewCache with the functions of the runtime.
+type Cache struct {
+	runtime *Runtime
+
+	mu       sync.Mutex
+	capacity int
+	// entries maps expressions to their element in order, the front of
+	// which is the most recently used expression.
+	entries map[string]*list.Element
+	order   *list.List
+	stats   CacheStats
+}
+
+// CacheStats counts the lookups made in a Cache.
+type CacheStats struct {
+	// Hits is the number of expressions found in the cache.
+	Hits uint64
+	// Misses is the number of expressions that had to be compiled.
+	Misses uint64
+	// Evictions is the number of expressions removed from the cache
+	// because it was full or resized.
+	Evictions uint64
+}
+
+type cacheEntry struct {
+	expression string
+	compiled   *JMESPath
+}
+
+// NewCache creates a cache holding up to capacity compiled expressions.
+// A cache with a capacity of 0 or less is disabled: it compiles the
+// expressions every time.
+func NewCache(capacity int) *Cache {
+	return NewRuntime().NewCache(capacity)
+}
+
+// NewCache creates a cache holding up to capacity expressions compiled
+// with the functions of the runtime. Expressions cached before a call to
+// RegisterFunction are compiled again with the new functions.
+func (r *Runtime) NewCache(capacity int) *Cache {
+	return &Cache{
+		runtime:  r,
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Compile returns the compiled expression, from the cache if it is there.
+// Expressions that fail to compile are not cached.
+func (c *Cache) Compile(expression string) (*JMESPath, error) {
+	c.mu.Lock()
+	if element, ok := c.entries[expression]; ok && c.current(element) {
+		c.order.MoveToFront(element)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return element.Value.(*cacheEntry).compiled, nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// The lock isn't held while compiling, so the same expression may be
+	// compiled more than once when it is searched concurrently.
+	compiled, err := c.runtime.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capacity <= 0 {
+		return compiled, nil
+	}
+	if element, ok := c.entries[expression]; ok {
+		c.order.MoveToFront(element)
+		if !c.current(element) {
+			element.Value.(*cacheEntry).compiled = compiled
+		}
+		return element.Value.(*cacheEntry).compiled, nil
+	}
+	c.entries[expression] = c.order.PushFront(&cacheEntry{expression: expression, compiled: compiled})
+	c.evict()
+	return compiled, nil
+}
+
+// Search evaluates a JMESPath expression against input data and returns
+// the result, compiling the expression only if it isn't in the cache.
+func (c *Cache) Search(expression string, data interface{}) (interface{}, error) {
+	compiled, err := c.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return compiled.Search(data)
+}
+
+// Resize changes the number of compiled expressions the cache can hold,
+// evicting the least recently used ones if there are too many. A capacity
+// of 0 or less empties and disables the cache.
+func (c *Cache) Resize(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capacity = capacity
+	c.evict()
+}
+
+// Len returns the number of compiled expressions in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns the number of hits, misses and evictions since the cache
+// was created.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// current reports whether the expression of element was compiled with the
+// current functions of the runtime. The caller must hold c.mu.
+func (c *Cache) current(element *list.Element) bool {
+	return element.Value.(*cacheEntry).compiled.intr.fCall == c.runtime.fCall
+}
+
+// evict removes the least recently used expressions until the cache fits
+// its capacity. The caller must hold c.mu.
+func (c *Cache) evict() {
+	for c.order.Len() > 0 && c.order.Len() > c.capacity {
+		element := c.order.Back()
+		c.order.Remove(element)
+		delete(c.entries, element.Value.(*cacheEntry).expression)
+		c.stats.Evictions++
+	}
+}
@@ -0,0 +1,177 @@
+package jmespath
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+func TestCacheHitsAndMisses(t *testing.T) {
+	assert := assert.New(t)
+	cache := NewCache(2)
+	data := map[string]interface{}{"foo": "bar"}
+	for i := 0; i < 3; i++ {
+		result, err := cache.Search("foo", data)
+		assert.Nil(err)
+		assert.Equal("bar", result)
+	}
+	assert.Equal(CacheStats{Hits: 2, Misses: 1}, cache.Stats())
+	assert.Equal(1, cache.Len())
+
+	first, err := cache.Compile("foo")
+	assert.Nil(err)
+	second, err := cache.Compile("foo")
+	assert.Nil(err)
+	assert.True(first == second)
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	assert := assert.New(t)
+	cache := NewCache(2)
+	compile := func(expression string) {
+		_, err := cache.Compile(expression)
+		assert.Nil(err)
+	}
+	compile("a")
+	compile("b")
+	compile("a")
+	compile("c")
+	assert.Equal(CacheStats{Hits: 1, Misses: 3, Evictions: 1}, cache.Stats())
+	compile("a")
+	compile("c")
+	assert.Equal(CacheStats{Hits: 3, Misses: 3, Evictions: 1}, cache.Stats())
+	compile("b")
+	assert.Equal(CacheStats{Hits: 3, Misses: 4, Evictions: 2}, cache.Stats())
+}
+
+func TestCacheDoesNotKeepErrors(t *testing.T) {
+	assert := assert.New(t)
+	cache := NewCache(2)
+	for i := 0; i < 2; i++ {
+		_, err := cache.Compile("foo[")
+		assert.NotNil(err)
+	}
+	assert.Equal(CacheStats{Misses: 2}, cache.Stats())
+	assert.Equal(0, cache.Len())
+}
+
+func TestCacheResize(t *testing.T) {
+	assert := assert.New(t)
+	cache := NewCache(3)
+	for _, expression := range []string{"a", "b", "c"} {
+		_, err := cache.Compile(expression)
+		assert.Nil(err)
+	}
+	cache.Resize(1)
+	assert.Equal(1, cache.Len())
+	_, err := cache.Compile("c")
+	assert.Nil(err)
+	assert.Equal(CacheStats{Hits: 1, Misses: 3, Evictions: 2}, cache.Stats())
+
+	// A cache with no capacity is disabled.
+	cache.Resize(0)
+	assert.Equal(0, cache.Len())
+	for i := 0; i < 2; i++ {
+		result, err := cache.Search("c", map[string]interface{}{"c": 1.0})
+		assert.Nil(err)
+		assert.Equal(1.0, result)
+	}
+	assert.Equal(0, cache.Len())
+	assert.Equal(CacheStats{Hits: 1, Misses: 5, Evictions: 3}, cache.Stats())
+}
+
+func TestCacheConcurrentUse(t *testing.T) {
+	assert := assert.New(t)
+	cache := NewCache(5)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("k%d", (i+j)%10)
+				result, err := cache.Search(key, map[string]interface{}{key: j})
+				assert.Nil(err)
+				assert.Equal(j, result)
+			}
+		}(i)
+	}
+	wg.Wait()
+	stats := cache.Stats()
+	assert.Equal(uint64(800), stats.Hits+stats.Misses)
+	assert.Equal(5, cache.Len())
+}
+
+func TestRuntimeCache(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	register := func(factor float64) {
+		err := runtime.RegisterFunction(FunctionEntry{
+			Name:      "scale",
+			Arguments: []ArgSpec{{Types: []JpType{JpNumber}}},
+			Handler: func(arguments []interface{}) (interface{}, error) {
+				return arguments[0].(float64) * factor, nil
+			},
+		})
+		assert.Nil(err)
+	}
+	register(2)
+	cache := runtime.NewCache(2)
+	for i := 0; i < 2; i++ {
+		result, err := cache.Search("scale(@)", 3.0)
+		assert.Nil(err)
+		assert.Equal(6.0, result)
+	}
+	assert.Equal(CacheStats{Hits: 1, Misses: 1}, cache.Stats())
+
+	// Cached expressions are compiled again with new functions.
+	register(10)
+	result, err := cache.Search("scale(@)", 3.0)
+	assert.Nil(err)
+	assert.Equal(30.0, result)
+	assert.Equal(CacheStats{Hits: 1, Misses: 2}, cache.Stats())
+	assert.Equal(1, cache.Len())
+	result, err = cache.Search("scale(@)", 3.0)
+	assert.Nil(err)
+	assert.Equal(30.0, result)
+	assert.Equal(CacheStats{Hits: 2, Misses: 2}, cache.Stats())
+
+	_, err = NewCache(2).Compile("scale(@)")
+	var unknownErr *UnknownFunctionError
+	assert.True(errors.As(err, &unknownErr))
+}
+
+func TestSearchUsesDefaultCache(t *testing.T) {
+	assert := assert.New(t)
+	before := DefaultCache().Stats()
+	for i := 0; i < 2; i++ {
+		_, err := Search("default_cache_test", nil)
+		assert.Nil(err)
+	}
+	after := DefaultCache().Stats()
+	assert.True(after.Hits > before.Hits)
+	assert.True(after.Misses > before.Misses)
+}
+
+func BenchmarkSearchCached(b *testing.B) {
+	data := map[string]interface{}{"foo": map[string]interface{}{"bar": "baz"}}
+	cache := NewCache(16)
+	b.Run("Cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := cache.Search("foo.bar", data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	disabled := NewCache(0)
+	b.Run("Uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := disabled.Search("foo.bar", data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
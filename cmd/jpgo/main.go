@@ -10,6 +10,20 @@ Evaluate the JMESPath expression against JSON data from a file:
 
     jp.go -input /tmp/data.json "foo.bar.baz"
 
+Evaluate the JMESPath expression against each record of newline-delimited
+JSON, or of a JSON array, printing one result per line:
+
+    jp.go -stream -input /tmp/records.ndjson "foo.bar.baz"
+
+Print how each node of the expression is evaluated against the data, to
+find out why it doesn't return the expected result:
+
+    jp.go -explain -input /tmp/data.json "foo.bar.baz"
+
+Print the same trace as JSON:
+
+    jp.go -explain -json -input /tmp/data.json "foo.bar.baz"
+
 This program can also be used as an executable to the jp-compliance
 runner (github.com/jmespath/jmespath.test).
 
@@ -17,6 +31,8 @@ runner (github.com/jmespath/jmespath.test).
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -38,7 +54,10 @@ func errMsg(msg string, a ...interface{}) int {
 func run() int {
 
 	astOnly := flag.Bool("ast", false, "Print the AST for the input expression and exit.")
+	explain := flag.Bool("explain", false, "Print the evaluation of each node of the AST instead of the result.")
+	explainJSON := flag.Bool("json", false, "With -explain, print the evaluation as JSON.")
 	inputFile := flag.String("input", "", "Filename containing JSON data to search. If not provided, data is read from stdin.")
+	stream := flag.Bool("stream", false, "Search each record of newline-delimited JSON, or of a JSON array, and print one result per line.")
 
 	flag.Parse()
 	args := flag.Args()
@@ -47,6 +66,16 @@ func run() int {
 		flag.PrintDefaults()
 		return errMsg("\nError: expected a single argument (the JMESPath expression).")
 	}
+	if *stream && *explain {
+		fmt.Fprintf(os.Stderr, "Usage:\n\n")
+		flag.PrintDefaults()
+		return errMsg("\nError: -stream and -explain can't be used together.")
+	}
+	if *explainJSON && !*explain {
+		fmt.Fprintf(os.Stderr, "Usage:\n\n")
+		flag.PrintDefaults()
+		return errMsg("\nError: -json can only be used with -explain.")
+	}
 
 	expression := args[0]
 	parser := jmespath.NewParser()
@@ -63,6 +92,10 @@ func run() int {
 		return 0
 	}
 
+	if *stream {
+		return runStream(expression, *inputFile)
+	}
+
 	var inputData []byte
 	if *inputFile != "" {
 		inputData, err = ioutil.ReadFile(*inputFile)
@@ -77,12 +110,16 @@ func run() int {
 			return errMsg("Error reading from stdin: %s", err)
 		}
 	}
-	var data interface{}
-	if err := json.Unmarshal(inputData, &data); err != nil {
-		return errMsg("Invalid input JSON: %s", err)
+	if *explain {
+		return runExplain(expression, inputData, *explainJSON)
 	}
-	result, err := jmespath.Search(expression, data)
+	// Only the parts of the input that the expression needs are decoded.
+	result, err := jmespath.SearchJSON(expression, inputData)
 	if err != nil {
+		var jsonError *json.SyntaxError
+		if errors.As(err, &jsonError) {
+			return errMsg("Invalid input JSON: %s", err)
+		}
 		return errMsg("Error executing expression: %s", err)
 	}
 	toJSON, err := json.MarshalIndent(result, "", "  ")
@@ -93,6 +130,67 @@ func run() int {
 	return 0
 }
 
+// runExplain prints the trace of the evaluation of the expression against
+// the input, as text or as JSON, which is printed up to the failing node
+// when the evaluation fails.
+func runExplain(expression string, inputData []byte, asJSON bool) int {
+	var data interface{}
+	if err := json.Unmarshal(inputData, &data); err != nil {
+		return errMsg("Invalid input JSON: %s", err)
+	}
+	_, trace, err := jmespath.Trace(expression, data)
+	if trace != nil && asJSON {
+		toJSON, err := json.MarshalIndent(trace, "", "  ")
+		if err != nil {
+			return errMsg("Error serializing trace to JSON: %s", err)
+		}
+		fmt.Println(string(toJSON))
+	} else if trace != nil {
+		fmt.Print(trace)
+	}
+	if err != nil {
+		return errMsg("Error executing expression: %s", err)
+	}
+	return 0
+}
+
+// runStream prints the result of the expression for each record read from
+// the input, one per line. Records that can't be searched are reported on
+// stderr, and don't stop the others from being searched.
+func runStream(expression string, inputFile string) int {
+	compiled, err := jmespath.Compile(expression)
+	if err != nil {
+		return errMsg("%s", err)
+	}
+	input := os.Stdin
+	if inputFile != "" {
+		input, err = os.Open(inputFile)
+		if err != nil {
+			return errMsg("Error loading file %s: %s", inputFile, err)
+		}
+		defer input.Close()
+	}
+	output := bufio.NewWriter(os.Stdout)
+	defer output.Flush()
+	encoder := json.NewEncoder(output)
+	status := 0
+	err = compiled.SearchStream(input, func(record int, result interface{}, err error) error {
+		if err == nil {
+			err = encoder.Encode(result)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error in record %d: %s\n", record, err)
+			status = 1
+		}
+		return nil
+	})
+	if err != nil {
+		output.Flush()
+		return errMsg("Error reading input: %s", err)
+	}
+	return status
+}
+
 func main() {
 	os.Exit(run())
 }
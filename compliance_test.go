@@ -2,6 +2,7 @@ package jmespath
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -97,7 +98,54 @@ func runSyntaxTestCase(assert *assert.Assertions, given interface{}, testcase Te
 	// Anything with an .Error means that we expect that JMESPath should return
 	// an error when we try to evaluate the expression.
 	_, err := Search(testcase.Expression, given)
-	assert.NotNil(err, fmt.Sprintf("Expression: %s", testcase.Expression))
+	if assert.NotNil(err, fmt.Sprintf("Expression: %s", testcase.Expression)) {
+		assert.Equal(testcase.Error, errorCategory(err), fmt.Sprintf("Expression: %s -- %s", testcase.Expression, err))
+	}
+	_, err = searchWithTreeInterpreter(testcase.Expression, given)
+	if assert.NotNil(err, fmt.Sprintf("Expression: %s", testcase.Expression)) {
+		assert.Equal(testcase.Error, errorCategory(err), fmt.Sprintf("Expression: %s -- %s", testcase.Expression, err))
+	}
+}
+
+// searchWithTreeInterpreter evaluates an expression with the tree
+// interpreter instead of the virtual machine used by Search.
+func searchWithTreeInterpreter(expression string, data interface{}) (interface{}, error) {
+	compiled, err := Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	intr := compiled.newEvaluation()
+	intr.root = data
+	return intr.Execute(compiled.ast, data)
+}
+
+// errorCategory returns the spec error category of err.
+func errorCategory(err error) string {
+	var (
+		syntaxError          SyntaxError
+		invalidTypeError     *InvalidTypeError
+		invalidArityError    *InvalidArityError
+		unknownFunctionError *UnknownFunctionError
+		invalidValueError    *InvalidValueError
+		undefinedVariable    *UndefinedVariableError
+	)
+	// Invalid function calls are syntax errors wrapping the runtime error,
+	// so the runtime categories are checked first.
+	switch {
+	case errors.As(err, &invalidTypeError):
+		return "invalid-type"
+	case errors.As(err, &invalidArityError):
+		return "invalid-arity"
+	case errors.As(err, &unknownFunctionError):
+		return "unknown-function"
+	case errors.As(err, &invalidValueError):
+		return "invalid-value"
+	case errors.As(err, &undefinedVariable):
+		return "undefined-variable"
+	case errors.As(err, &syntaxError):
+		return "syntax"
+	}
+	return "unknown"
 }
 
 func runTestCase(assert *assert.Assertions, given interface{}, testcase TestCase, filename string) {
@@ -120,4 +168,8 @@ func runTestCase(assert *assert.Assertions, given interface{}, testcase TestCase
 	if assert.Nil(err, fmt.Sprintf("Expression: %s", testcase.Expression)) {
 		assert.Equal(testcase.Result, actual, fmt.Sprintf("Expression: %s", testcase.Expression))
 	}
+	actual, err = searchWithTreeInterpreter(testcase.Expression, given)
+	if assert.Nil(err, fmt.Sprintf("Expression: %s", testcase.Expression)) {
+		assert.Equal(testcase.Result, actual, fmt.Sprintf("Expression: %s", testcase.Expression))
+	}
 }
@@ -0,0 +1,113 @@
+package jmespath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The runtime errors below correspond to the error categories of the
+// JMESPath specification. They are returned by Search and can be told
+// apart with errors.As.
+
+// InvalidTypeError is returned when a function or operator is given a
+// value of a type it does not accept.
+type InvalidTypeError struct {
+	// Function is the name of the function, or the symbol of the
+	// operator, that rejected the value.
+	Function string
+	// ArgIndex is the zero based index of the rejected argument.
+	ArgIndex int
+	Expected []JpType
+	Actual   JpType
+}
+
+func (e *InvalidTypeError) Error() string {
+	expected := make([]string, len(e.Expected))
+	for i, t := range e.Expected {
+		expected[i] = string(t)
+	}
+	return fmt.Sprintf("invalid type for argument %d of %s: expected %s, got %s",
+		e.ArgIndex, e.Function, strings.Join(expected, " or "), e.Actual)
+}
+
+// InvalidArityError is returned when a function is called with the wrong
+// number of arguments.
+type InvalidArityError struct {
+	Function string
+	// Expected is the number of arguments the function takes, or the
+	// minimum number of arguments if it is variadic.
+	Expected int
+	Variadic bool
+	Actual   int
+}
+
+func (e *InvalidArityError) Error() string {
+	qualifier := ""
+	if e.Variadic {
+		qualifier = "at least "
+	}
+	return fmt.Sprintf("invalid arity for %s: expected %s%d arguments, got %d",
+		e.Function, qualifier, e.Expected, e.Actual)
+}
+
+// UnknownFunctionError is returned when an expression calls a function
+// that is not registered.
+type UnknownFunctionError struct {
+	Function string
+}
+
+func (e *UnknownFunctionError) Error() string {
+	return "unknown function: " + e.Function
+}
+
+// UndefinedVariableError is returned when an expression refers to a
+// variable that no enclosing let expression binds.
+type UndefinedVariableError struct {
+	// Name is the name of the variable, without the leading $.
+	Name string
+}
+
+func (e *UndefinedVariableError) Error() string {
+	return "undefined variable: $" + e.Name
+}
+
+// InvalidValueError is returned when a value has the right type but is
+// not accepted, such as a slice step of zero or a division by zero.
+type InvalidValueError struct {
+	// Function is the name of the function, or the symbol of the
+	// operator, that rejected the value.
+	Function string
+	// ArgIndex is the zero based index of the rejected argument.
+	ArgIndex int
+	Reason   string
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("invalid value for argument %d of %s: %s", e.ArgIndex, e.Function, e.Reason)
+}
+
+// jpTypeOf returns the JMESPath type of a value.
+func jpTypeOf(value interface{}) JpType {
+	switch value.(type) {
+	case nil:
+		return JpNull
+	case float64:
+		return JpNumber
+	case string:
+		return JpString
+	case bool:
+		return JpBoolean
+	case map[string]interface{}:
+		return JpObject
+	case expRef:
+		return JpExpref
+	}
+	if isNumber(value) {
+		return JpNumber
+	}
+	if isSliceType(value) {
+		return JpArray
+	}
+	// Structs and other maps are looked up like objects.
+	return JpObject
+}
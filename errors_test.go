@@ -0,0 +1,114 @@
+package jmespath
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+func TestInvalidTypeError(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		expression string
+		expected   InvalidTypeError
+	}{
+		{"abs(s)", InvalidTypeError{Function: "abs", ArgIndex: 0, Expected: []JpType{JpNumber}, Actual: JpString}},
+		{"starts_with(s, n)", InvalidTypeError{Function: "starts_with", ArgIndex: 1, Expected: []JpType{JpString}, Actual: JpNumber}},
+		{"length(missing)", InvalidTypeError{Function: "length", ArgIndex: 0, Expected: []JpType{JpString, JpArray, JpObject}, Actual: JpNull}},
+		{"sort_by(items, &a)", InvalidTypeError{Function: "sort_by", ArgIndex: 1, Expected: []JpType{JpString}, Actual: JpNumber}},
+		{"max_by(items, &a)", InvalidTypeError{Function: "max_by", ArgIndex: 1, Expected: []JpType{JpString}, Actual: JpNumber}},
+		{"min_by(items, &[a])", InvalidTypeError{Function: "min_by", ArgIndex: 1, Expected: []JpType{JpNumber, JpString}, Actual: JpArray}},
+		{"max_by(items, &[a])", InvalidTypeError{Function: "max_by", ArgIndex: 1, Expected: []JpType{JpNumber, JpString}, Actual: JpArray}},
+		{"n + s", InvalidTypeError{Function: "+", ArgIndex: 1, Expected: []JpType{JpNumber}, Actual: JpString}},
+		{"-s", InvalidTypeError{Function: "-", ArgIndex: 0, Expected: []JpType{JpNumber}, Actual: JpString}},
+	}
+	for _, tt := range tests {
+		data := map[string]interface{}{
+			"s":     "foo",
+			"n":     1.0,
+			"items": []interface{}{map[string]interface{}{"a": "x"}, map[string]interface{}{"a": 1.0}},
+		}
+		_, err := Search(tt.expression, data)
+		var typeErr *InvalidTypeError
+		if assert.True(errors.As(err, &typeErr), tt.expression) {
+			assert.Equal(tt.expected, *typeErr, tt.expression)
+		}
+	}
+}
+
+func TestInvalidArityError(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		expression string
+		expected   InvalidArityError
+	}{
+		{"abs(`1`, `2`)", InvalidArityError{Function: "abs", Expected: 1, Actual: 2}},
+		{"not_null()", InvalidArityError{Function: "not_null", Expected: 1, Variadic: true, Actual: 0}},
+	}
+	for _, tt := range tests {
+		_, err := Search(tt.expression, nil)
+		var arityErr *InvalidArityError
+		if assert.True(errors.As(err, &arityErr), tt.expression) {
+			assert.Equal(tt.expected, *arityErr, tt.expression)
+		}
+	}
+	assert.Equal("invalid arity for not_null: expected at least 1 arguments, got 0", (&InvalidArityError{Function: "not_null", Expected: 1, Variadic: true}).Error())
+}
+
+func TestUnknownFunctionError(t *testing.T) {
+	assert := assert.New(t)
+	_, err := Search("nosuch(@)", nil)
+	var unknownErr *UnknownFunctionError
+	if assert.True(errors.As(err, &unknownErr)) {
+		assert.Equal("nosuch", unknownErr.Function)
+		assert.Equal("unknown function: nosuch", unknownErr.Error())
+	}
+}
+
+func TestInvalidValueError(t *testing.T) {
+	assert := assert.New(t)
+	data := map[string]interface{}{"n": 1.0, "items": []interface{}{1.0, 2.0}}
+	for _, expression := range []string{"items[::0]", "n / `0`", "n // `0`", "n % `0`", "n * `1e308` * `10`"} {
+		_, err := Search(expression, data)
+		var valueErr *InvalidValueError
+		assert.True(errors.As(err, &valueErr), expression)
+	}
+}
+
+func TestUndefinedVariableError(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		expression string
+		name       string
+		offset     int
+	}{
+		{"$foo", "foo", 0},
+		{"[let $x = `1` in $x, $x]", "x", 21},
+		{"let $x = $x in $x", "x", 9},
+		{"let $x = `1`, $y = $x in $y", "x", 19},
+		{"foo[?bar == $baz]", "baz", 12},
+		{"map(&$x, let $x = `1` in [$x])", "x", 5},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.expression)
+		var syntaxErr SyntaxError
+		if assert.True(errors.As(err, &syntaxErr), tt.expression) {
+			assert.Equal(tt.offset, syntaxErr.Offset, tt.expression)
+		}
+		var undefinedErr *UndefinedVariableError
+		if assert.True(errors.As(err, &undefinedErr), tt.expression) {
+			assert.Equal(tt.name, undefinedErr.Name, tt.expression)
+		}
+	}
+
+	// Expressions evaluated without being compiled fail when the variable
+	// is looked up.
+	parsed, err := NewParser().Parse("$foo")
+	assert.Nil(err)
+	_, err = newInterpreter().Execute(parsed, nil)
+	var undefinedErr *UndefinedVariableError
+	if assert.True(errors.As(err, &undefinedErr)) {
+		assert.Equal("undefined variable: $foo", undefinedErr.Error())
+	}
+}
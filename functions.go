@@ -3,7 +3,6 @@ package jmespath
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"math"
 	"reflect"
 	"sort"
@@ -12,38 +11,65 @@ import (
 	"unicode/utf8"
 )
 
-type jpFunction func(arguments []interface{}) (interface{}, error)
+// JpFunction is the implementation of a JMESPath function. It receives the
+// evaluated arguments, which have already been checked against the argument
+// specs of the function.
+type JpFunction func(arguments []interface{}) (interface{}, error)
 
-type jpType string
+// JpType is a JMESPath type that a function argument may accept.
+type JpType string
 
 const (
-	jpNumber      jpType = "number"
-	jpString      jpType = "string"
-	jpArray       jpType = "array"
-	jpObject      jpType = "object"
-	jpArrayNumber jpType = "array[number]"
-	jpArrayString jpType = "array[string]"
-	jpExpref      jpType = "expref"
-	jpAny         jpType = "any"
+	JpNumber      JpType = "number"
+	JpString      JpType = "string"
+	JpArray       JpType = "array"
+	JpObject      JpType = "object"
+	JpBoolean     JpType = "boolean"
+	JpArrayNumber JpType = "array[number]"
+	JpArrayString JpType = "array[string]"
+	JpExpref      JpType = "expref"
+	JpNull        JpType = "null"
+	JpAny         JpType = "any"
 )
 
-type functionEntry struct {
-	name      string
-	arguments []argSpec
-	handler   jpFunction
+// FunctionEntry describes a JMESPath function: its name, the arguments it
+// accepts, and the handler that implements it.
+type FunctionEntry struct {
+	Name      string
+	Arguments []ArgSpec
+	Handler   JpFunction
+	// Builtin functions that take an expref receive the interpreter
+	// as their first argument.
 	hasExpRef bool
+	// builtin is set for the functions of the JMESPath specification,
+	// which always return the same result for the same arguments.
+	builtin bool
 }
 
-type argSpec struct {
-	types    []jpType
-	variadic bool
+// ArgSpec describes a single function argument. An argument may accept any
+// of the listed Types. Only the last argument of a function may be Variadic,
+// in which case it matches one or more trailing arguments, or zero or more
+// if it is also Optional.
+type ArgSpec struct {
+	Types    []JpType
+	Variadic bool
+	Optional bool
+}
+
+// ExpRef is the value passed to a function for an argument of type JpExpref,
+// e.g. the &bar in sort_by(foo, &bar).
+type ExpRef interface {
+	// Evaluate applies the referenced expression to value.
+	Evaluate(value interface{}) (interface{}, error)
 }
 
 type byExprString struct {
-	intr     *treeInterpreter
-	node     ASTNode
-	items    []interface{}
-	hasError bool
+	intr  *treeInterpreter
+	node  ASTNode
+	items []interface{}
+	// err records the first error seen while comparing, as sort.Interface
+	// has no way to report it.
+	err error
 }
 
 func (a *byExprString) Len() int {
@@ -53,340 +79,491 @@ func (a *byExprString) Swap(i, j int) {
 	a.items[i], a.items[j] = a.items[j], a.items[i]
 }
 func (a *byExprString) Less(i, j int) bool {
-	first, err := a.intr.Execute(a.node, a.items[i])
-	if err != nil {
-		a.hasError = true
-		// Return a dummy value.
+	if a.err != nil {
 		return true
 	}
-	ith, ok := first.(string)
-	if !ok {
-		a.hasError = true
+	if err := a.intr.checkContext(); err != nil {
+		a.err = err
 		return true
 	}
-	second, err := a.intr.Execute(a.node, a.items[j])
-	if err != nil {
-		a.hasError = true
-		// Return a dummy value.
+	ith, ok := a.key(a.items[i])
+	if !ok {
 		return true
 	}
-	jth, ok := second.(string)
+	jth, ok := a.key(a.items[j])
 	if !ok {
-		a.hasError = true
 		return true
 	}
 	return ith < jth
 }
 
-type byExprFloat struct {
-	intr     *treeInterpreter
-	node     ASTNode
-	items    []interface{}
-	hasError bool
+// key evaluates the sort key of item, recording any error in a.err.
+func (a *byExprString) key(item interface{}) (string, bool) {
+	result, err := a.intr.Execute(a.node, item)
+	if err != nil {
+		a.err = err
+		return "", false
+	}
+	key, ok := result.(string)
+	if !ok {
+		a.err = &InvalidTypeError{
+			Function: "sort_by",
+			ArgIndex: 1,
+			Expected: []JpType{JpString},
+			Actual:   jpTypeOf(result),
+		}
+	}
+	return key, ok
+}
+
+type byExprNumber struct {
+	intr  *treeInterpreter
+	node  ASTNode
+	items []interface{}
+	// err records the first error seen while comparing, as sort.Interface
+	// has no way to report it.
+	err error
 }
 
-func (a *byExprFloat) Len() int {
+func (a *byExprNumber) Len() int {
 	return len(a.items)
 }
-func (a *byExprFloat) Swap(i, j int) {
+func (a *byExprNumber) Swap(i, j int) {
 	a.items[i], a.items[j] = a.items[j], a.items[i]
 }
-func (a *byExprFloat) Less(i, j int) bool {
-	first, err := a.intr.Execute(a.node, a.items[i])
-	if err != nil {
-		a.hasError = true
-		// Return a dummy value.
+func (a *byExprNumber) Less(i, j int) bool {
+	if a.err != nil {
 		return true
 	}
-	ith, ok := first.(float64)
-	if !ok {
-		a.hasError = true
+	if err := a.intr.checkContext(); err != nil {
+		a.err = err
 		return true
 	}
-	second, err := a.intr.Execute(a.node, a.items[j])
-	if err != nil {
-		a.hasError = true
-		// Return a dummy value.
+	ith, ok := a.key(a.items[i])
+	if !ok {
 		return true
 	}
-	jth, ok := second.(float64)
+	jth, ok := a.key(a.items[j])
 	if !ok {
-		a.hasError = true
 		return true
 	}
-	return ith < jth
+	order, _ := compareNumbers(ith, jth)
+	return order < 0
+}
+
+// key evaluates the sort key of item, recording any error in a.err.
+func (a *byExprNumber) key(item interface{}) (interface{}, bool) {
+	result, err := a.intr.Execute(a.node, item)
+	if err != nil {
+		a.err = err
+		return nil, false
+	}
+	ok := isNumber(result)
+	if !ok {
+		a.err = &InvalidTypeError{
+			Function: "sort_by",
+			ArgIndex: 1,
+			Expected: []JpType{JpNumber},
+			Actual:   jpTypeOf(result),
+		}
+	}
+	return result, ok
 }
 
 type functionCaller struct {
-	functionTable map[string]functionEntry
+	functionTable map[string]FunctionEntry
 }
 
 func newFunctionCaller() *functionCaller {
 	caller := &functionCaller{}
-	caller.functionTable = map[string]functionEntry{
+	caller.functionTable = map[string]FunctionEntry{
 		"length": {
-			name: "length",
-			arguments: []argSpec{
-				{types: []jpType{jpString, jpArray, jpObject}},
+			Name: "length",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString, JpArray, JpObject}},
 			},
-			handler: jpfLength,
+			Handler: jpfLength,
 		},
 		"starts_with": {
-			name: "starts_with",
-			arguments: []argSpec{
-				{types: []jpType{jpString}},
-				{types: []jpType{jpString}},
+			Name: "starts_with",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+				{Types: []JpType{JpString}},
 			},
-			handler: jpfStartsWith,
+			Handler: jpfStartsWith,
 		},
 		"abs": {
-			name: "abs",
-			arguments: []argSpec{
-				{types: []jpType{jpNumber}},
+			Name: "abs",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpNumber}},
 			},
-			handler: jpfAbs,
+			Handler: jpfAbs,
 		},
 		"avg": {
-			name: "avg",
-			arguments: []argSpec{
-				{types: []jpType{jpArrayNumber}},
+			Name: "avg",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpArrayNumber}},
 			},
-			handler: jpfAvg,
+			Handler: jpfAvg,
 		},
 		"ceil": {
-			name: "ceil",
-			arguments: []argSpec{
-				{types: []jpType{jpNumber}},
+			Name: "ceil",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpNumber}},
 			},
-			handler: jpfCeil,
+			Handler: jpfCeil,
 		},
 		"contains": {
-			name: "contains",
-			arguments: []argSpec{
-				{types: []jpType{jpArray, jpString}},
-				{types: []jpType{jpAny}},
+			Name: "contains",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpArray, JpString}},
+				{Types: []JpType{JpAny}},
 			},
-			handler: jpfContains,
+			Handler: jpfContains,
 		},
 		"ends_with": {
-			name: "ends_with",
-			arguments: []argSpec{
-				{types: []jpType{jpString}},
-				{types: []jpType{jpString}},
+			Name: "ends_with",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+				{Types: []JpType{JpString}},
 			},
-			handler: jpfEndsWith,
+			Handler: jpfEndsWith,
 		},
 		"floor": {
-			name: "floor",
-			arguments: []argSpec{
-				{types: []jpType{jpNumber}},
+			Name: "floor",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpNumber}},
 			},
-			handler: jpfFloor,
+			Handler: jpfFloor,
 		},
 		"map": {
-			name: "amp",
-			arguments: []argSpec{
-				{types: []jpType{jpExpref}},
-				{types: []jpType{jpArray}},
+			Name: "map",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpExpref}},
+				{Types: []JpType{JpArray}},
 			},
-			handler:   jpfMap,
+			Handler:   jpfMap,
 			hasExpRef: true,
 		},
 		"max": {
-			name: "max",
-			arguments: []argSpec{
-				{types: []jpType{jpArrayNumber, jpArrayString}},
+			Name: "max",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpArrayNumber, JpArrayString}},
 			},
-			handler: jpfMax,
+			Handler: jpfMax,
 		},
 		"merge": {
-			name: "merge",
-			arguments: []argSpec{
-				{types: []jpType{jpObject}, variadic: true},
+			Name: "merge",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpObject}, Variadic: true},
 			},
-			handler: jpfMerge,
+			Handler: jpfMerge,
 		},
 		"max_by": {
-			name: "max_by",
-			arguments: []argSpec{
-				{types: []jpType{jpArray}},
-				{types: []jpType{jpExpref}},
+			Name: "max_by",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpArray}},
+				{Types: []JpType{JpExpref}},
 			},
-			handler:   jpfMaxBy,
+			Handler:   jpfMaxBy,
 			hasExpRef: true,
 		},
 		"sum": {
-			name: "sum",
-			arguments: []argSpec{
-				{types: []jpType{jpArrayNumber}},
+			Name: "sum",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpArrayNumber}},
 			},
-			handler: jpfSum,
+			Handler: jpfSum,
 		},
 		"min": {
-			name: "min",
-			arguments: []argSpec{
-				{types: []jpType{jpArrayNumber, jpArrayString}},
+			Name: "min",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpArrayNumber, JpArrayString}},
 			},
-			handler: jpfMin,
+			Handler: jpfMin,
 		},
 		"min_by": {
-			name: "min_by",
-			arguments: []argSpec{
-				{types: []jpType{jpArray}},
-				{types: []jpType{jpExpref}},
+			Name: "min_by",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpArray}},
+				{Types: []JpType{JpExpref}},
 			},
-			handler:   jpfMinBy,
+			Handler:   jpfMinBy,
 			hasExpRef: true,
 		},
 		"type": {
-			name: "type",
-			arguments: []argSpec{
-				{types: []jpType{jpAny}},
+			Name: "type",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpAny}},
 			},
-			handler: jpfType,
+			Handler: jpfType,
 		},
 		"keys": {
-			name: "keys",
-			arguments: []argSpec{
-				{types: []jpType{jpObject}},
+			Name: "keys",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpObject}},
 			},
-			handler: jpfKeys,
+			Handler: jpfKeys,
 		},
 		"values": {
-			name: "values",
-			arguments: []argSpec{
-				{types: []jpType{jpObject}},
+			Name: "values",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpObject}},
 			},
-			handler: jpfValues,
+			Handler: jpfValues,
 		},
 		"sort": {
-			name: "sort",
-			arguments: []argSpec{
-				{types: []jpType{jpArrayString, jpArrayNumber}},
+			Name: "sort",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpArrayString, JpArrayNumber}},
 			},
-			handler: jpfSort,
+			Handler: jpfSort,
 		},
 		"sort_by": {
-			name: "sort_by",
-			arguments: []argSpec{
-				{types: []jpType{jpArray}},
-				{types: []jpType{jpExpref}},
+			Name: "sort_by",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpArray}},
+				{Types: []JpType{JpExpref}},
 			},
-			handler:   jpfSortBy,
+			Handler:   jpfSortBy,
 			hasExpRef: true,
 		},
 		"join": {
-			name: "join",
-			arguments: []argSpec{
-				{types: []jpType{jpString}},
-				{types: []jpType{jpArrayString}},
+			Name: "join",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpString}},
+				{Types: []JpType{JpArrayString}},
 			},
-			handler: jpfJoin,
+			Handler: jpfJoin,
 		},
 		"reverse": {
-			name: "reverse",
-			arguments: []argSpec{
-				{types: []jpType{jpArray, jpString}},
+			Name: "reverse",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpArray, JpString}},
 			},
-			handler: jpfReverse,
+			Handler: jpfReverse,
 		},
 		"to_array": {
-			name: "to_array",
-			arguments: []argSpec{
-				{types: []jpType{jpAny}},
+			Name: "to_array",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpAny}},
 			},
-			handler: jpfToArray,
+			Handler: jpfToArray,
 		},
 		"to_string": {
-			name: "to_string",
-			arguments: []argSpec{
-				{types: []jpType{jpAny}},
+			Name: "to_string",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpAny}},
 			},
-			handler: jpfToString,
+			Handler: jpfToString,
 		},
 		"to_number": {
-			name: "to_number",
-			arguments: []argSpec{
-				{types: []jpType{jpAny}},
+			Name: "to_number",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpAny}},
 			},
-			handler: jpfToNumber,
+			Handler: jpfToNumber,
 		},
 		"not_null": {
-			name: "not_null",
-			arguments: []argSpec{
-				{types: []jpType{jpAny}, variadic: true},
+			Name: "not_null",
+			Arguments: []ArgSpec{
+				{Types: []JpType{JpAny}, Variadic: true},
 			},
-			handler: jpfNotNull,
+			Handler: jpfNotNull,
 		},
 	}
+	for name, entry := range caller.functionTable {
+		entry.builtin = true
+		caller.functionTable[name] = entry
+	}
 	return caller
 }
 
-func (e *functionEntry) resolveArgs(arguments []interface{}) ([]interface{}, error) {
-	if len(e.arguments) == 0 {
-		return arguments, nil
+// with returns a copy of the function caller that also knows about entry.
+// The receiver is left untouched so that expressions already using it
+// keep their function table.
+func (f *functionCaller) with(entry FunctionEntry) *functionCaller {
+	table := make(map[string]FunctionEntry, len(f.functionTable)+1)
+	for name, existing := range f.functionTable {
+		table[name] = existing
 	}
-	if !e.arguments[len(e.arguments)-1].variadic {
-		if len(e.arguments) != len(arguments) {
-			return nil, errors.New("incorrect number of args")
+	table[entry.Name] = entry
+	return &functionCaller{functionTable: table}
+}
+
+// checkArity returns an *InvalidArityError if the function does not accept
+// count arguments.
+func (e *FunctionEntry) checkArity(count int) error {
+	if len(e.Arguments) == 0 {
+		if count != 0 {
+			return &InvalidArityError{Function: e.Name, Expected: 0, Actual: count}
 		}
-		for i, spec := range e.arguments {
-			userArg := arguments[i]
-			err := spec.typeCheck(userArg)
-			if err != nil {
-				return nil, err
-			}
+		return nil
+	}
+	last := e.Arguments[len(e.Arguments)-1]
+	if !last.Variadic {
+		if len(e.Arguments) != count {
+			return &InvalidArityError{Function: e.Name, Expected: len(e.Arguments), Actual: count}
 		}
+		return nil
+	}
+	minimum := len(e.Arguments)
+	if last.Optional {
+		minimum--
+	}
+	if count < minimum {
+		return &InvalidArityError{Function: e.Name, Expected: minimum, Variadic: true, Actual: count}
+	}
+	return nil
+}
+
+func (e *FunctionEntry) resolveArgs(arguments []interface{}) ([]interface{}, error) {
+	if err := e.checkArity(len(arguments)); err != nil {
+		return nil, err
+	}
+	if len(arguments) == 0 {
 		return arguments, nil
 	}
-	if len(arguments) < len(e.arguments) {
-		return nil, errors.New("invalid arity")
+	last := e.Arguments[len(e.Arguments)-1]
+	for i, userArg := range arguments {
+		spec := last
+		if i < len(e.Arguments) {
+			spec = e.Arguments[i]
+		}
+		if !spec.typeCheck(userArg) {
+			return nil, &InvalidTypeError{
+				Function: e.Name,
+				ArgIndex: i,
+				Expected: spec.Types,
+				Actual:   jpTypeOf(userArg),
+			}
+		}
+		// Functions get arrays as []interface{}, whatever the type of
+		// the slice holding them in the data.
+		if _, ok := userArg.([]interface{}); !ok && spec.acceptsArray() && isSliceType(userArg) {
+			arguments[i] = projectionItems(userArg)
+		}
 	}
 	return arguments, nil
 }
 
-func (a *argSpec) typeCheck(arg interface{}) error {
-	for _, t := range a.types {
+// acceptsArray determines if the argument may be an array.
+func (a *ArgSpec) acceptsArray() bool {
+	for _, t := range a.Types {
+		switch t {
+		case JpArray, JpArrayNumber, JpArrayString:
+			return true
+		}
+	}
+	return false
+}
+
+func (a *ArgSpec) typeCheck(arg interface{}) bool {
+	for _, t := range a.Types {
 		switch t {
-		case jpNumber:
-			if _, ok := arg.(float64); ok {
-				return nil
+		case JpNumber:
+			if isNumber(arg) {
+				return true
 			}
-		case jpString:
+		case JpString:
 			if _, ok := arg.(string); ok {
-				return nil
+				return true
 			}
-		case jpArray:
+		case JpArray:
 			if isSliceType(arg) {
-				return nil
+				return true
+			}
+		case JpObject:
+			if isMapType(arg) {
+				return true
 			}
-		case jpObject:
-			if _, ok := arg.(map[string]interface{}); ok {
-				return nil
+		case JpBoolean:
+			if _, ok := arg.(bool); ok {
+				return true
 			}
-		case jpArrayNumber:
+		case JpNull:
+			if arg == nil {
+				return true
+			}
+		case JpArrayNumber:
 			if _, ok := toArrayNum(arg); ok {
-				return nil
+				return true
 			}
-		case jpArrayString:
+		case JpArrayString:
 			if _, ok := toArrayStr(arg); ok {
-				return nil
+				return true
 			}
-		case jpAny:
-			return nil
-		case jpExpref:
+		case JpAny:
+			return true
+		case JpExpref:
 			if _, ok := arg.(expRef); ok {
-				return nil
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validate checks that every function called in node is known and is
+// called with an accepted number of arguments, so that invalid calls are
+// reported when an expression is compiled rather than when it is searched.
+func (f *functionCaller) validate(node ASTNode, expression string) error {
+	return f.validateIn(node, expression, nil)
+}
+
+// validateIn validates node, where bound holds the variables bound by the
+// let expressions enclosing it.
+func (f *functionCaller) validateIn(node ASTNode, expression string, bound *scope) error {
+	var err error
+	switch node.NodeType {
+	case ASTFunctionExpression:
+		name := node.Value.(string)
+		if entry, ok := f.functionTable[name]; ok {
+			err = entry.checkArity(len(node.Children))
+		} else {
+			err = &UnknownFunctionError{Function: name}
+		}
+	case ASTVariable:
+		name := node.Value.(string)
+		if _, ok := bound.lookup(name); !ok {
+			err = &UndefinedVariableError{Name: name}
+		}
+	case ASTLetExpression:
+		// The bound expressions are validated in the enclosing scope,
+		// the body in the new one.
+		bindings := node.Children[:len(node.Children)-1]
+		inner := &scope{
+			variables: make(map[string]interface{}, len(bindings)),
+			parent:    bound,
+		}
+		for _, binding := range bindings {
+			if err := f.validateIn(binding.Children[0], expression, bound); err != nil {
+				return err
 			}
+			inner.variables[binding.Value.(string)] = nil
+		}
+		return f.validateIn(node.Children[len(node.Children)-1], expression, inner)
+	}
+	if err != nil {
+		return SyntaxError{
+			msg:        err.Error(),
+			Expression: expression,
+			Offset:     node.Span.Start,
+			err:        err,
+		}
+	}
+	for _, child := range node.Children {
+		if err := f.validateIn(child, expression, bound); err != nil {
+			return err
 		}
 	}
-	return fmt.Errorf("Invalid type for: %v, expected: %#v", arg, a.types)
+	return nil
 }
 
 func (f *functionCaller) CallFunction(name string, arguments []interface{}, intr *treeInterpreter) (interface{}, error) {
 	entry, ok := f.functionTable[name]
 	if !ok {
-		return nil, errors.New("unknown function: " + name)
+		return nil, &UnknownFunctionError{Function: name}
 	}
 	resolvedArgs, err := entry.resolveArgs(arguments)
 	if err != nil {
@@ -397,11 +574,11 @@ func (f *functionCaller) CallFunction(name string, arguments []interface{}, intr
 		extra = append(extra, intr)
 		resolvedArgs = append(extra, resolvedArgs...)
 	}
-	return entry.handler(resolvedArgs)
+	return entry.Handler(resolvedArgs)
 }
 
 func jpfAbs(arguments []interface{}) (interface{}, error) {
-	num := arguments[0].(float64)
+	num, _ := toFloat(arguments[0])
 	return math.Abs(num), nil
 }
 
@@ -412,8 +589,8 @@ func jpfLength(arguments []interface{}) (interface{}, error) {
 	} else if isSliceType(arg) {
 		v := reflect.ValueOf(arg)
 		return float64(v.Len()), nil
-	} else if c, ok := arg.(map[string]interface{}); ok {
-		return float64(len(c)), nil
+	} else if v, ok := mapValue(arg); ok {
+		return float64(v.Len()), nil
 	}
 	return nil, errors.New("could not compute length()")
 }
@@ -427,16 +604,16 @@ func jpfStartsWith(arguments []interface{}) (interface{}, error) {
 func jpfAvg(arguments []interface{}) (interface{}, error) {
 	// We've already type checked the value so we can safely use
 	// type assertions.
-	args := arguments[0].([]interface{})
+	args, _ := toArrayNum(arguments[0])
 	length := float64(len(args))
 	numerator := 0.0
 	for _, n := range args {
-		numerator += n.(float64)
+		numerator += n
 	}
 	return numerator / length, nil
 }
 func jpfCeil(arguments []interface{}) (interface{}, error) {
-	val := arguments[0].(float64)
+	val, _ := toFloat(arguments[0])
 	return math.Ceil(val), nil
 }
 func jpfContains(arguments []interface{}) (interface{}, error) {
@@ -451,7 +628,7 @@ func jpfContains(arguments []interface{}) (interface{}, error) {
 	// Otherwise this is a generic contains for []interface{}
 	general := search.([]interface{})
 	for _, item := range general {
-		if item == el {
+		if objsEqual(item, el) {
 			return true, nil
 		}
 	}
@@ -463,7 +640,7 @@ func jpfEndsWith(arguments []interface{}) (interface{}, error) {
 	return strings.HasSuffix(search, suffix), nil
 }
 func jpfFloor(arguments []interface{}) (interface{}, error) {
-	val := arguments[0].(float64)
+	val, _ := toFloat(arguments[0])
 	return math.Floor(val), nil
 }
 func jpfMap(arguments []interface{}) (interface{}, error) {
@@ -473,6 +650,9 @@ func jpfMap(arguments []interface{}) (interface{}, error) {
 	arr := arguments[2].([]interface{})
 	mapped := make([]interface{}, 0, len(arr))
 	for _, value := range arr {
+		if err := intr.checkContext(); err != nil {
+			return nil, err
+		}
 		current, err := intr.Execute(node, value)
 		if err != nil {
 			return nil, err
@@ -482,20 +662,8 @@ func jpfMap(arguments []interface{}) (interface{}, error) {
 	return mapped, nil
 }
 func jpfMax(arguments []interface{}) (interface{}, error) {
-	if items, ok := toArrayNum(arguments[0]); ok {
-		if len(items) == 0 {
-			return nil, nil
-		}
-		if len(items) == 1 {
-			return items[0], nil
-		}
-		best := items[0]
-		for _, item := range items[1:] {
-			if item > best {
-				best = item
-			}
-		}
-		return best, nil
+	if _, ok := toArrayNum(arguments[0]); ok {
+		return bestNumber(arguments[0].([]interface{}), 1), nil
 	}
 	// Otherwise we're dealing with a max() of strings.
 	items, _ := toArrayStr(arguments[0])
@@ -516,7 +684,7 @@ func jpfMax(arguments []interface{}) (interface{}, error) {
 func jpfMerge(arguments []interface{}) (interface{}, error) {
 	final := make(map[string]interface{})
 	for _, m := range arguments {
-		mapped := m.(map[string]interface{})
+		mapped, _ := toObject(m)
 		for key, value := range mapped {
 			final[key] = value
 		}
@@ -538,35 +706,25 @@ func jpfMaxBy(arguments []interface{}) (interface{}, error) {
 		return nil, err
 	}
 	switch t := start.(type) {
-	case float64:
+	case string:
 		bestVal := t
 		bestItem := arr[0]
 		for _, item := range arr[1:] {
-			result, err := intr.Execute(node, item)
-			if err != nil {
+			if err := intr.checkContext(); err != nil {
 				return nil, err
 			}
-			current, ok := result.(float64)
-			if !ok {
-				return nil, errors.New("invalid type, must be number")
-			}
-			if current > bestVal {
-				bestVal = current
-				bestItem = item
-			}
-		}
-		return bestItem, nil
-	case string:
-		bestVal := t
-		bestItem := arr[0]
-		for _, item := range arr[1:] {
 			result, err := intr.Execute(node, item)
 			if err != nil {
 				return nil, err
 			}
 			current, ok := result.(string)
 			if !ok {
-				return nil, errors.New("invalid type, must be string")
+				return nil, &InvalidTypeError{
+					Function: "max_by",
+					ArgIndex: 1,
+					Expected: []JpType{JpString},
+					Actual:   jpTypeOf(result),
+				}
 			}
 			if current > bestVal {
 				bestVal = current
@@ -575,33 +733,56 @@ func jpfMaxBy(arguments []interface{}) (interface{}, error) {
 		}
 		return bestItem, nil
 	default:
-		return nil, errors.New("invalid type, must be number of string")
+		if isNumber(start) {
+			return bestByNumber(intr, "max_by", arr, start, node, 1)
+		}
+		return nil, &InvalidTypeError{
+			Function: "max_by",
+			ArgIndex: 1,
+			Expected: []JpType{JpNumber, JpString},
+			Actual:   jpTypeOf(start),
+		}
+	}
+}
+
+// bestNumber returns the largest of the numbers if order is 1, or the
+// smallest if order is -1, or nil if there are no numbers. The number is
+// returned as it is, so that large integers keep their precision.
+func bestNumber(items []interface{}, order int) interface{} {
+	if len(items) == 0 {
+		return nil
+	}
+	best := items[0]
+	for _, item := range items[1:] {
+		if current, _ := compareNumbers(item, best); current == order {
+			best = item
+		}
 	}
+	return best
 }
+
 func jpfSum(arguments []interface{}) (interface{}, error) {
-	items, _ := toArrayNum(arguments[0])
+	// Integers are added exactly for as long as their sum fits in an
+	// int64.
+	var exact int64
 	sum := 0.0
-	for _, item := range items {
-		sum += item
+	for _, item := range arguments[0].([]interface{}) {
+		n, _ := toNumber(item)
+		if n.kind == intNumber {
+			next := exact + n.i
+			if (next > exact) == (n.i > 0) {
+				exact = next
+				continue
+			}
+		}
+		sum += n.float()
 	}
-	return sum, nil
+	return sum + float64(exact), nil
 }
 
 func jpfMin(arguments []interface{}) (interface{}, error) {
-	if items, ok := toArrayNum(arguments[0]); ok {
-		if len(items) == 0 {
-			return nil, nil
-		}
-		if len(items) == 1 {
-			return items[0], nil
-		}
-		best := items[0]
-		for _, item := range items[1:] {
-			if item < best {
-				best = item
-			}
-		}
-		return best, nil
+	if _, ok := toArrayNum(arguments[0]); ok {
+		return bestNumber(arguments[0].([]interface{}), -1), nil
 	}
 	items, _ := toArrayStr(arguments[0])
 	if len(items) == 0 {
@@ -633,35 +814,27 @@ func jpfMinBy(arguments []interface{}) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	if t, ok := start.(float64); ok {
+	if isNumber(start) {
+		return bestByNumber(intr, "min_by", arr, start, node, -1)
+	} else if t, ok := start.(string); ok {
 		bestVal := t
 		bestItem := arr[0]
 		for _, item := range arr[1:] {
-			result, err := intr.Execute(node, item)
-			if err != nil {
+			if err := intr.checkContext(); err != nil {
 				return nil, err
 			}
-			current, ok := result.(float64)
-			if !ok {
-				return nil, errors.New("invalid type, must be number")
-			}
-			if current < bestVal {
-				bestVal = current
-				bestItem = item
-			}
-		}
-		return bestItem, nil
-	} else if t, ok := start.(string); ok {
-		bestVal := t
-		bestItem := arr[0]
-		for _, item := range arr[1:] {
 			result, err := intr.Execute(node, item)
 			if err != nil {
 				return nil, err
 			}
 			current, ok := result.(string)
 			if !ok {
-				return nil, errors.New("invalid type, must be string")
+				return nil, &InvalidTypeError{
+					Function: "min_by",
+					ArgIndex: 1,
+					Expected: []JpType{JpString},
+					Actual:   jpTypeOf(result),
+				}
 			}
 			if current < bestVal {
 				bestVal = current
@@ -670,12 +843,49 @@ func jpfMinBy(arguments []interface{}) (interface{}, error) {
 		}
 		return bestItem, nil
 	} else {
-		return nil, errors.New("invalid type, must be number of string")
+		return nil, &InvalidTypeError{
+			Function: "min_by",
+			ArgIndex: 1,
+			Expected: []JpType{JpNumber, JpString},
+			Actual:   jpTypeOf(start),
+		}
 	}
 }
+
+// bestByNumber returns the item of arr for which node evaluates to the
+// largest number if order is 1, or to the smallest if order is -1. start is
+// the number of the first item.
+func bestByNumber(intr *treeInterpreter, function string, arr []interface{}, start interface{}, node ASTNode, order int) (interface{}, error) {
+	bestVal := start
+	bestItem := arr[0]
+	for _, item := range arr[1:] {
+		if err := intr.checkContext(); err != nil {
+			return nil, err
+		}
+		result, err := intr.Execute(node, item)
+		if err != nil {
+			return nil, err
+		}
+		current, ok := compareNumbers(result, bestVal)
+		if !ok && !isNumber(result) {
+			return nil, &InvalidTypeError{
+				Function: function,
+				ArgIndex: 1,
+				Expected: []JpType{JpNumber},
+				Actual:   jpTypeOf(result),
+			}
+		}
+		if current == order {
+			bestVal = result
+			bestItem = item
+		}
+	}
+	return bestItem, nil
+}
+
 func jpfType(arguments []interface{}) (interface{}, error) {
 	arg := arguments[0]
-	if _, ok := arg.(float64); ok {
+	if isNumber(arg) {
 		return "number", nil
 	}
 	if _, ok := arg.(string); ok {
@@ -684,7 +894,7 @@ func jpfType(arguments []interface{}) (interface{}, error) {
 	if _, ok := arg.([]interface{}); ok {
 		return "array", nil
 	}
-	if _, ok := arg.(map[string]interface{}); ok {
+	if isMapType(arg) {
 		return "object", nil
 	}
 	if arg == nil {
@@ -696,7 +906,7 @@ func jpfType(arguments []interface{}) (interface{}, error) {
 	return nil, errors.New("unknown type")
 }
 func jpfKeys(arguments []interface{}) (interface{}, error) {
-	arg := arguments[0].(map[string]interface{})
+	arg, _ := toObject(arguments[0])
 	collected := make([]interface{}, 0, len(arg))
 	for key := range arg {
 		collected = append(collected, key)
@@ -704,7 +914,7 @@ func jpfKeys(arguments []interface{}) (interface{}, error) {
 	return collected, nil
 }
 func jpfValues(arguments []interface{}) (interface{}, error) {
-	arg := arguments[0].(map[string]interface{})
+	arg, _ := toObject(arguments[0])
 	collected := make([]interface{}, 0, len(arg))
 	for _, value := range arg {
 		collected = append(collected, value)
@@ -712,13 +922,14 @@ func jpfValues(arguments []interface{}) (interface{}, error) {
 	return collected, nil
 }
 func jpfSort(arguments []interface{}) (interface{}, error) {
-	if items, ok := toArrayNum(arguments[0]); ok {
-		d := sort.Float64Slice(items)
-		sort.Stable(d)
-		final := make([]interface{}, len(d))
-		for i, val := range d {
-			final[i] = val
-		}
+	if _, ok := toArrayNum(arguments[0]); ok {
+		items := arguments[0].([]interface{})
+		final := make([]interface{}, len(items))
+		copy(final, items)
+		sort.SliceStable(final, func(i, j int) bool {
+			order, _ := compareNumbers(final[i], final[j])
+			return order < 0
+		})
 		return final, nil
 	}
 	// Otherwise we're dealing with sort()'ing strings.
@@ -745,22 +956,33 @@ func jpfSortBy(arguments []interface{}) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	if _, ok := start.(float64); ok {
-		sortable := &byExprFloat{intr, node, arr, false}
+	if isNumber(start) {
+		sortable := &byExprNumber{intr: intr, node: node, items: arr}
 		sort.Stable(sortable)
-		if sortable.hasError {
-			return nil, errors.New("error in sort_by comparison")
+		if err := intr.checkContext(); err != nil {
+			return nil, err
+		}
+		if sortable.err != nil {
+			return nil, sortable.err
 		}
 		return arr, nil
 	} else if _, ok := start.(string); ok {
-		sortable := &byExprString{intr, node, arr, false}
+		sortable := &byExprString{intr: intr, node: node, items: arr}
 		sort.Stable(sortable)
-		if sortable.hasError {
-			return nil, errors.New("error in sort_by comparison")
+		if err := intr.checkContext(); err != nil {
+			return nil, err
+		}
+		if sortable.err != nil {
+			return nil, sortable.err
 		}
 		return arr, nil
 	} else {
-		return nil, errors.New("invalid type, must be number of string")
+		return nil, &InvalidTypeError{
+			Function: "sort_by",
+			ArgIndex: 1,
+			Expected: []JpType{JpNumber, JpString},
+			Actual:   jpTypeOf(start),
+		}
 	}
 }
 func jpfJoin(arguments []interface{}) (interface{}, error) {
@@ -807,8 +1029,8 @@ func jpfToString(arguments []interface{}) (interface{}, error) {
 }
 func jpfToNumber(arguments []interface{}) (interface{}, error) {
 	arg := arguments[0]
-	if v, ok := arg.(float64); ok {
-		return v, nil
+	if isNumber(arg) {
+		return arg, nil
 	}
 	if v, ok := arg.(string); ok {
 		conv, err := strconv.ParseFloat(v, 64)
@@ -820,7 +1042,7 @@ func jpfToNumber(arguments []interface{}) (interface{}, error) {
 	if _, ok := arg.([]interface{}); ok {
 		return nil, nil
 	}
-	if _, ok := arg.(map[string]interface{}); ok {
+	if isMapType(arg) {
 		return nil, nil
 	}
 	if arg == nil {
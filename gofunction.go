@@ -0,0 +1,240 @@
+package jmespath
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	expRefType = reflect.TypeOf((*ExpRef)(nil)).Elem()
+)
+
+// NewFunctionEntry creates a FunctionEntry for the Go function fn. The
+// argument specs are derived from the parameters of fn:
+//
+//	string                   string
+//	bool                     boolean
+//	int, uint, float kinds   number
+//	[]string                 array[string]
+//	slice of a number kind   array[number]
+//	[]interface{}            array
+//	map[string]interface{}   object
+//	ExpRef                   expref
+//	interface{}              any
+//
+// If fn is variadic, so is its last argument, which like in Go may be given
+// no values at all. fn must return either a single value, or a value and an
+// error. Arguments are converted to the parameter types before fn is called,
+// and numbers, slices and maps in the returned value are converted back to
+// float64, []interface{} and map[string]interface{}.
+func NewFunctionEntry(name string, fn interface{}) (FunctionEntry, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return FunctionEntry{}, fmt.Errorf("function %s: expected a func, got %T", name, fn)
+	}
+	ft := fv.Type()
+	switch {
+	case ft.NumOut() == 1:
+	case ft.NumOut() == 2 && ft.Out(1) == errorType:
+	default:
+		return FunctionEntry{}, fmt.Errorf("function %s: must return a value, or a value and an error", name)
+	}
+	params := make([]reflect.Type, ft.NumIn())
+	specs := make([]ArgSpec, ft.NumIn())
+	for i := range params {
+		params[i] = ft.In(i)
+		if ft.IsVariadic() && i == ft.NumIn()-1 {
+			params[i] = params[i].Elem()
+			specs[i].Variadic = true
+			specs[i].Optional = true
+		}
+		t, ok := jpTypeOfGoType(params[i])
+		if !ok {
+			return FunctionEntry{}, fmt.Errorf("function %s: unsupported type %s for argument %d", name, params[i], i)
+		}
+		specs[i].Types = []JpType{t}
+	}
+	handler := func(arguments []interface{}) (interface{}, error) {
+		in := make([]reflect.Value, len(arguments))
+		for i, arg := range arguments {
+			param := params[len(params)-1]
+			if i < len(params) {
+				param = params[i]
+			}
+			v, err := toGoValue(arg, param)
+			if err != nil {
+				return nil, fmt.Errorf("function %s: argument %d: %s", name, i, err)
+			}
+			in[i] = v
+		}
+		out := fv.Call(in)
+		if len(out) == 2 && !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+		return fromGoValue(out[0]), nil
+	}
+	return FunctionEntry{Name: name, Arguments: specs, Handler: handler}, nil
+}
+
+// RegisterFunc registers the Go function fn under name. See NewFunctionEntry
+// for how the argument specs are derived from fn.
+func (r *Runtime) RegisterFunc(name string, fn interface{}) error {
+	entry, err := NewFunctionEntry(name, fn)
+	if err != nil {
+		return err
+	}
+	return r.RegisterFunction(entry)
+}
+
+func jpTypeOfGoType(t reflect.Type) (JpType, bool) {
+	if t == expRefType {
+		return JpExpref, true
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return JpAny, true
+		}
+	case reflect.String:
+		return JpString, true
+	case reflect.Bool:
+		return JpBoolean, true
+	case reflect.Slice:
+		elem := t.Elem()
+		if elem.Kind() == reflect.String {
+			return JpArrayString, true
+		}
+		if isNumberKind(elem.Kind()) {
+			return JpArrayNumber, true
+		}
+		if elem.Kind() == reflect.Interface && elem.NumMethod() == 0 {
+			return JpArray, true
+		}
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.Interface && t.Elem().NumMethod() == 0 {
+			return JpObject, true
+		}
+	default:
+		if isNumberKind(t.Kind()) {
+			return JpNumber, true
+		}
+	}
+	return "", false
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// toGoValue converts a type checked argument to the Go type t.
+func toGoValue(arg interface{}, t reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		return reflect.Zero(t), nil
+	}
+	v := reflect.ValueOf(arg)
+	switch t.Kind() {
+	case reflect.Interface:
+		return v, nil
+	case reflect.Slice:
+		s := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem, err := toGoValue(v.Index(i).Interface(), t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			s.Index(i).Set(elem)
+		}
+		return s, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toNumber(arg)
+		if !ok {
+			break
+		}
+		i, reason := n.int()
+		if reason == "" && reflect.Zero(t).OverflowInt(i) {
+			reason = reasonOutOfRange
+		}
+		if reason != "" {
+			return reflect.Value{}, fmt.Errorf("%s does not fit in %s: %s", n, t, reason)
+		}
+		return reflect.ValueOf(i).Convert(t), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := toNumber(arg)
+		if !ok {
+			break
+		}
+		u, reason := n.uint()
+		if reason == "" && reflect.Zero(t).OverflowUint(u) {
+			reason = reasonOutOfRange
+		}
+		if reason != "" {
+			return reflect.Value{}, fmt.Errorf("%s does not fit in %s: %s", n, t, reason)
+		}
+		return reflect.ValueOf(u).Convert(t), nil
+	case reflect.Float32, reflect.Float64:
+		if f, ok := toFloat(arg); ok {
+			return reflect.ValueOf(f).Convert(t), nil
+		}
+	}
+	if !v.Type().ConvertibleTo(t) {
+		return reflect.Value{}, errors.New("cannot convert " + v.Type().String() + " to " + t.String())
+	}
+	return v.Convert(t), nil
+}
+
+// fromGoValue converts the result of a Go function into the types
+// used by the interpreter.
+func fromGoValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			return fromGoValue(v.Elem())
+		}
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = fromGoValue(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = fromGoValue(iter.Value())
+		}
+		return m
+	}
+	return v.Interface()
+}
@@ -0,0 +1,152 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+func TestNewFunctionEntryDerivesArgSpecs(t *testing.T) {
+	assert := assert.New(t)
+	entry, err := NewFunctionEntry("f", func(s string, n int, b bool, a []string, o map[string]interface{}, x interface{}, rest ...float64) string {
+		return s
+	})
+	assert.Nil(err)
+	assert.Equal("f", entry.Name)
+	assert.Equal([]ArgSpec{
+		{Types: []JpType{JpString}},
+		{Types: []JpType{JpNumber}},
+		{Types: []JpType{JpBoolean}},
+		{Types: []JpType{JpArrayString}},
+		{Types: []JpType{JpObject}},
+		{Types: []JpType{JpAny}},
+		{Types: []JpType{JpNumber}, Variadic: true, Optional: true},
+	}, entry.Arguments)
+}
+
+func TestNewFunctionEntryRejectsUnsupportedFuncs(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewFunctionEntry("f", "not a func")
+	assert.NotNil(err)
+	_, err = NewFunctionEntry("f", func() {})
+	assert.NotNil(err)
+	_, err = NewFunctionEntry("f", func() (string, string) { return "", "" })
+	assert.NotNil(err)
+	_, err = NewFunctionEntry("f", func(c chan int) string { return "" })
+	assert.NotNil(err)
+}
+
+func TestRegisterFuncConvertsArgumentsAndResults(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	err := runtime.RegisterFunc("repeat", func(s string, n int) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		return strings.Repeat(s, n), nil
+	})
+	assert.Nil(err)
+	err = runtime.RegisterFunc("lengths", func(items ...string) []int {
+		lengths := make([]int, len(items))
+		for i, item := range items {
+			lengths[i] = len(item)
+		}
+		return lengths
+	})
+	assert.Nil(err)
+	data := map[string]interface{}{"s": "ab", "n": 3.0}
+
+	result, err := runtime.Search("repeat(s, n)", data)
+	assert.Nil(err)
+	assert.Equal("ababab", result)
+
+	result, err = runtime.Search("lengths(s, 'abc')", data)
+	assert.Nil(err)
+	assert.Equal([]interface{}{2.0, 3.0}, result)
+
+	// Like in Go, variadic functions can be called without variadic
+	// arguments.
+	result, err = runtime.Search("lengths()", data)
+	assert.Nil(err)
+	assert.Equal([]interface{}{}, result)
+	err = runtime.RegisterFunc("concat", func(sep string, items ...string) string {
+		return strings.Join(items, sep)
+	})
+	assert.Nil(err)
+	result, err = runtime.Search("concat('-')", data)
+	assert.Nil(err)
+	assert.Equal("", result)
+	_, err = runtime.Compile("concat()")
+	var arityErr *InvalidArityError
+	if assert.True(errors.As(err, &arityErr)) {
+		assert.Equal(InvalidArityError{Function: "concat", Expected: 1, Variadic: true, Actual: 0}, *arityErr)
+	}
+
+	_, err = runtime.Search("repeat(s, `-1`)", data)
+	assert.EqualError(err, "negative count")
+	_, err = runtime.Search("repeat(s, `1.5`)", data)
+	assert.NotNil(err)
+	_, err = runtime.Search("repeat(n, s)", data)
+	assert.NotNil(err)
+}
+
+func TestRegisterFuncWithExpRef(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	err := runtime.RegisterFunc("apply", func(ref ExpRef, value interface{}) (interface{}, error) {
+		return ref.Evaluate(value)
+	})
+	assert.Nil(err)
+	result, err := runtime.Search("apply(&foo, @)", map[string]interface{}{"foo": "bar"})
+	assert.Nil(err)
+	assert.Equal("bar", result)
+}
+
+func TestRegisterFuncConvertsNumbersOfAnyType(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	err := runtime.RegisterFunc("add", func(a int, b uint8, c float32) float64 {
+		return float64(a) + float64(b) + float64(c)
+	})
+	assert.Nil(err)
+	err = runtime.RegisterFunc("total", func(values []int) int {
+		total := 0
+		for _, value := range values {
+			total += value
+		}
+		return total
+	})
+	assert.Nil(err)
+
+	data := map[string]interface{}{"a": 3, "b": uint(4), "c": int64(5), "values": []int{1, 2, 3}}
+	result, err := runtime.Search("add(a, b, c)", data)
+	assert.Nil(err)
+	assert.Equal(12.0, result)
+	result, err = runtime.Search("total(values)", data)
+	assert.Nil(err)
+	assert.Equal(6.0, result)
+	result, err = runtime.Search("total([a, c])", data)
+	assert.Nil(err)
+	assert.Equal(8.0, result)
+
+	decoder := json.NewDecoder(strings.NewReader(`{"a": 3, "b": 4, "c": 0.5, "values": [1, 2, 3], "big": 300, "half": 1.5}`))
+	decoder.UseNumber()
+	var numbers interface{}
+	assert.Nil(decoder.Decode(&numbers))
+	result, err = runtime.Search("add(a, b, c)", numbers)
+	assert.Nil(err)
+	assert.Equal(7.5, result)
+	result, err = runtime.Search("total(values)", numbers)
+	assert.Nil(err)
+	assert.Equal(6.0, result)
+
+	_, err = runtime.Search("add(a, big, c)", numbers)
+	assert.EqualError(err, "function add: argument 1: 300 does not fit in uint8: out of range")
+	_, err = runtime.Search("add(half, b, c)", numbers)
+	assert.EqualError(err, "function add: argument 0: 1.5 does not fit in int: not an integer")
+	_, err = runtime.Search("add(a, `-1`, c)", data)
+	assert.EqualError(err, "function add: argument 1: -1 does not fit in uint8: out of range")
+}
@@ -1,10 +1,10 @@
 package jmespath
 
 import (
+	"context"
 	"errors"
+	"math"
 	"reflect"
-	"unicode"
-	"unicode/utf8"
 )
 
 /* This is a tree based interpreter.  It walks the AST and directly
@@ -13,6 +13,67 @@ import (
 
 type treeInterpreter struct {
 	fCall *functionCaller
+	// ctx is checked for cancellation while iterating over
+	// collections. It is nil when evaluation can't be canceled.
+	ctx context.Context
+	// limits bounds the resources used by an evaluation, steps and
+	// depth track the usage so far.
+	limits Limits
+	steps  int
+	depth  int
+	// scope holds the variables bound by the enclosing let expressions.
+	scope *scope
+	// root is the document the evaluation started with, referred
+	// to by "$".
+	root interface{}
+	// tracer records the evaluation of each node, it is only set
+	// by Trace.
+	tracer *tracer
+}
+
+// scope is a set of variables bound by a let expression. Variables
+// not found in a scope are looked up in its parent.
+type scope struct {
+	variables map[string]interface{}
+	parent    *scope
+}
+
+func (s *scope) lookup(name string) (interface{}, bool) {
+	for ; s != nil; s = s.parent {
+		if value, ok := s.variables[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// CanceledError is returned when an evaluation is stopped because its
+// context was canceled or its deadline passed. Err is the error returned
+// by the context, so errors.Is(err, context.DeadlineExceeded) can be used
+// to tell the two apart.
+type CanceledError struct {
+	Err error
+}
+
+func (e *CanceledError) Error() string {
+	return "evaluation canceled: " + e.Err.Error()
+}
+
+// Unwrap returns the context error.
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// checkContext returns a *CanceledError if the context of the
+// interpreter is done.
+func (intr *treeInterpreter) checkContext() error {
+	if intr.ctx == nil {
+		return nil
+	}
+	if err := intr.ctx.Err(); err != nil {
+		return &CanceledError{Err: err}
+	}
+	return nil
 }
 
 func newInterpreter() *treeInterpreter {
@@ -22,69 +83,104 @@ func newInterpreter() *treeInterpreter {
 }
 
 type expRef struct {
-	ref ASTNode
+	ref   ASTNode
+	intr  *treeInterpreter
+	scope *scope
+}
+
+// Evaluate applies the referenced expression to value, with the
+// variables that were in scope where the expref was created.
+func (e expRef) Evaluate(value interface{}) (interface{}, error) {
+	outer := e.intr.scope
+	e.intr.scope = e.scope
+	defer func() { e.intr.scope = outer }()
+	return e.intr.Execute(e.ref, value)
 }
 
 // Execute takes an ASTNode and input data and interprets the AST directly.
 // It will produce the result of applying the JMESPath expression associated
 // with the ASTNode to the input data "value".
 func (intr *treeInterpreter) Execute(node ASTNode, value interface{}) (interface{}, error) {
-	switch node.nodeType {
+	if intr.tracer != nil {
+		return intr.traceExecute(node, value)
+	}
+	return intr.executeLimited(node, value)
+}
+
+// executeLimited evaluates node, enforcing the limits of the evaluation.
+func (intr *treeInterpreter) executeLimited(node ASTNode, value interface{}) (interface{}, error) {
+	if intr.limits == (Limits{}) {
+		return intr.execute(node, value)
+	}
+	if err := intr.enter(); err != nil {
+		return nil, err
+	}
+	result, err := intr.execute(node, value)
+	intr.depth--
+	if err == nil {
+		err = intr.checkCollection(node.NodeType, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (intr *treeInterpreter) execute(node ASTNode, value interface{}) (interface{}, error) {
+	switch node.NodeType {
 	case ASTComparator:
-		left, err := intr.Execute(node.children[0], value)
+		left, err := intr.Execute(node.Children[0], value)
 		if err != nil {
 			return nil, err
 		}
-		right, err := intr.Execute(node.children[1], value)
+		right, err := intr.Execute(node.Children[1], value)
 		if err != nil {
 			return nil, err
 		}
-		switch node.value {
-		case tEQ:
-			return objsEqual(left, right), nil
-		case tNE:
-			return !objsEqual(left, right), nil
-		}
-		leftNum, ok := left.(float64)
-		if !ok {
-			return nil, nil
-		}
-		rightNum, ok := right.(float64)
-		if !ok {
-			return nil, nil
-		}
-		switch node.value {
-		case tGT:
-			return leftNum > rightNum, nil
-		case tGTE:
-			return leftNum >= rightNum, nil
-		case tLT:
-			return leftNum < rightNum, nil
-		case tLTE:
-			return leftNum <= rightNum, nil
-		}
+		return compare(node.Value.(tokType), left, right), nil
 	case ASTExpRef:
-		return expRef{ref: node.children[0]}, nil
+		return expRef{ref: node.Children[0], intr: intr, scope: intr.scope}, nil
 	case ASTFunctionExpression:
 		resolvedArgs := []interface{}{}
-		for _, arg := range node.children {
+		for _, arg := range node.Children {
 			current, err := intr.Execute(arg, value)
 			if err != nil {
 				return nil, err
 			}
 			resolvedArgs = append(resolvedArgs, current)
 		}
-		return intr.fCall.CallFunction(node.value.(string), resolvedArgs, intr)
+		return intr.fCall.CallFunction(node.Value.(string), resolvedArgs, intr)
 	case ASTField:
-		if m, ok := value.(map[string]interface{}); ok {
-			key := node.value.(string)
-			return m[key], nil
+		return intr.field(node.Value.(string), value)
+	case ASTFirstMatch:
+		left, err := intr.Execute(node.Children[0], value)
+		if err != nil {
+			return nil, err
+		}
+		for _, element := range projectionItems(left) {
+			if err := intr.checkContext(); err != nil {
+				return nil, err
+			}
+			result, err := intr.Execute(node.Children[2], element)
+			if err != nil {
+				return nil, err
+			}
+			if isFalse(result) {
+				continue
+			}
+			current, err := intr.Execute(node.Children[1], element)
+			if err != nil {
+				return nil, err
+			}
+			if current != nil {
+				return current, nil
+			}
 		}
-		return intr.fieldFromStruct(node.value.(string), value)
+		return nil, nil
 	case ASTFilterProjection:
-		left, err := intr.Execute(node.children[0], value)
+		left, err := intr.Execute(node.Children[0], value)
 		if err != nil {
-			return nil, nil
+			return nil, err
 		}
 		sliceType, ok := left.([]interface{})
 		if !ok {
@@ -93,97 +189,61 @@ func (intr *treeInterpreter) Execute(node ASTNode, value interface{}) (interface
 			}
 			return nil, nil
 		}
-		compareNode := node.children[2]
+		compareNode := node.Children[2]
 		collected := []interface{}{}
 		for _, element := range sliceType {
+			if err := intr.checkContext(); err != nil {
+				return nil, err
+			}
 			result, err := intr.Execute(compareNode, element)
 			if err != nil {
 				return nil, err
 			}
 			if !isFalse(result) {
-				current, err := intr.Execute(node.children[1], element)
+				current, err := intr.Execute(node.Children[1], element)
 				if err != nil {
 					return nil, err
 				}
 				if current != nil {
 					collected = append(collected, current)
+					if err := intr.checkCollectionSize(len(collected)); err != nil {
+						return nil, err
+					}
 				}
 			}
 		}
 		return collected, nil
 	case ASTFlatten:
-		left, err := intr.Execute(node.children[0], value)
+		left, err := intr.Execute(node.Children[0], value)
 		if err != nil {
-			return nil, nil
-		}
-		sliceType, ok := left.([]interface{})
-		if !ok {
-			// If we can't type convert to []interface{}, there's
-			// a chance this could still work via reflection if we're
-			// dealing with user provided types.
-			if isSliceType(left) {
-				return intr.flattenWithReflection(left)
-			}
-			return nil, nil
-		}
-		flattened := []interface{}{}
-		for _, element := range sliceType {
-			if elementSlice, ok := element.([]interface{}); ok {
-				flattened = append(flattened, elementSlice...)
-			} else if isSliceType(element) {
-				reflectFlat := []interface{}{}
-				v := reflect.ValueOf(element)
-				for i := 0; i < v.Len(); i++ {
-					reflectFlat = append(reflectFlat, v.Index(i).Interface())
-				}
-				flattened = append(flattened, reflectFlat...)
-			} else {
-				flattened = append(flattened, element)
-			}
+			return nil, err
 		}
-		return flattened, nil
+		return intr.flatten(left)
 	case ASTIdentity, ASTCurrentNode:
 		return value, nil
+	case ASTRootNode:
+		return intr.root, nil
 	case ASTIndex:
-		if sliceType, ok := value.([]interface{}); ok {
-			index := node.value.(int)
-			if index < 0 {
-				index += len(sliceType)
-			}
-			if index < len(sliceType) && index >= 0 {
-				return sliceType[index], nil
-			}
-			return nil, nil
-		}
-		// Otherwise try via reflection.
-		rv := reflect.ValueOf(value)
-		if rv.Kind() == reflect.Slice {
-			index := node.value.(int)
-			if index < 0 {
-				index += rv.Len()
-			}
-			if index < rv.Len() && index >= 0 {
-				v := rv.Index(index)
-				return v.Interface(), nil
-			}
-		}
-		return nil, nil
+		return index(value, node.Value.(int)), nil
 	case ASTKeyValPair:
-		return intr.Execute(node.children[0], value)
+		return intr.Execute(node.Children[0], value)
 	case ASTLiteral:
-		return node.value, nil
+		return node.Value, nil
 	case ASTMultiSelectHash:
 		if value == nil {
 			return nil, nil
 		}
 		collected := make(map[string]interface{})
-		for _, child := range node.children {
+		for _, child := range node.Children {
 			current, err := intr.Execute(child, value)
 			if err != nil {
 				return nil, err
 			}
-			key := child.value.(string)
+			key := child.Value.(string)
 			collected[key] = current
+			if err := intr.checkCollectionSize(len(collected)); err != nil {
+				return nil, err
+			}
 		}
 		return collected, nil
 	case ASTMultiSelectList:
@@ -191,37 +251,49 @@ func (intr *treeInterpreter) Execute(node ASTNode, value interface{}) (interface
 			return nil, nil
 		}
 		collected := []interface{}{}
-		for _, child := range node.children {
+		for _, child := range node.Children {
 			current, err := intr.Execute(child, value)
 			if err != nil {
 				return nil, err
 			}
 			collected = append(collected, current)
+			if err := intr.checkCollectionSize(len(collected)); err != nil {
+				return nil, err
+			}
 		}
 		return collected, nil
 	case ASTOrExpression:
-		matched, err := intr.Execute(node.children[0], value)
+		matched, err := intr.Execute(node.Children[0], value)
 		if err != nil {
 			return nil, err
 		}
 		if isFalse(matched) {
-			matched, err = intr.Execute(node.children[1], value)
+			matched, err = intr.Execute(node.Children[1], value)
 			if err != nil {
 				return nil, err
 			}
 		}
 		return matched, nil
 	case ASTAndExpression:
-		matched, err := intr.Execute(node.children[0], value)
+		matched, err := intr.Execute(node.Children[0], value)
 		if err != nil {
 			return nil, err
 		}
 		if isFalse(matched) {
 			return matched, nil
 		}
-		return intr.Execute(node.children[1], value)
+		return intr.Execute(node.Children[1], value)
+	case ASTTernaryExpression:
+		condition, err := intr.Execute(node.Children[0], value)
+		if err != nil {
+			return nil, err
+		}
+		if isFalse(condition) {
+			return intr.Execute(node.Children[2], value)
+		}
+		return intr.Execute(node.Children[1], value)
 	case ASTNotExpression:
-		matched, err := intr.Execute(node.children[0], value)
+		matched, err := intr.Execute(node.Children[0], value)
 		if err != nil {
 			return nil, err
 		}
@@ -232,7 +304,7 @@ func (intr *treeInterpreter) Execute(node ASTNode, value interface{}) (interface
 	case ASTPipe:
 		result := value
 		var err error
-		for _, child := range node.children {
+		for _, child := range node.Children {
 			result, err = intr.Execute(child, result)
 			if err != nil {
 				return nil, err
@@ -240,7 +312,7 @@ func (intr *treeInterpreter) Execute(node ASTNode, value interface{}) (interface
 		}
 		return result, nil
 	case ASTProjection:
-		left, err := intr.Execute(node.children[0], value)
+		left, err := intr.Execute(node.Children[0], value)
 		if err != nil {
 			return nil, err
 		}
@@ -254,95 +326,346 @@ func (intr *treeInterpreter) Execute(node ASTNode, value interface{}) (interface
 		collected := []interface{}{}
 		var current interface{}
 		for _, element := range sliceType {
-			current, err = intr.Execute(node.children[1], element)
+			if err := intr.checkContext(); err != nil {
+				return nil, err
+			}
+			current, err = intr.Execute(node.Children[1], element)
 			if err != nil {
 				return nil, err
 			}
 			if current != nil {
 				collected = append(collected, current)
+				if err := intr.checkCollectionSize(len(collected)); err != nil {
+					return nil, err
+				}
 			}
 		}
 		return collected, nil
 	case ASTSubexpression, ASTIndexExpression:
-		left, err := intr.Execute(node.children[0], value)
+		left, err := intr.Execute(node.Children[0], value)
 		if err != nil {
 			return nil, err
 		}
-		return intr.Execute(node.children[1], left)
+		return intr.Execute(node.Children[1], left)
 	case ASTSlice:
-		sliceType, ok := value.([]interface{})
-		if !ok {
-			if isSliceType(value) {
-				return intr.sliceWithReflection(node, value)
-			}
-			return nil, nil
-		}
-		parts := node.value.([]*int)
-		sliceParams := make([]sliceParam, 3)
-		for i, part := range parts {
-			if part != nil {
-				sliceParams[i].Specified = true
-				sliceParams[i].N = *part
-			}
-		}
-		return slice(sliceType, sliceParams)
+		return intr.sliceOf(node.Value.([]*int), value)
 	case ASTValueProjection:
-		left, err := intr.Execute(node.children[0], value)
+		left, err := intr.Execute(node.Children[0], value)
 		if err != nil {
-			return nil, nil
+			return nil, err
 		}
-		mapType, ok := left.(map[string]interface{})
+		mapType, ok := toObject(left)
 		if !ok {
 			return nil, nil
 		}
-		values := make([]interface{}, len(mapType))
+		values := make([]interface{}, 0, len(mapType))
 		for _, value := range mapType {
 			values = append(values, value)
 		}
 		collected := []interface{}{}
 		for _, element := range values {
-			current, err := intr.Execute(node.children[1], element)
+			if err := intr.checkContext(); err != nil {
+				return nil, err
+			}
+			current, err := intr.Execute(node.Children[1], element)
 			if err != nil {
 				return nil, err
 			}
 			if current != nil {
 				collected = append(collected, current)
+				if err := intr.checkCollectionSize(len(collected)); err != nil {
+					return nil, err
+				}
 			}
 		}
 		return collected, nil
+	case ASTLetExpression:
+		bindings := node.Children[:len(node.Children)-1]
+		inner := &scope{
+			variables: make(map[string]interface{}, len(bindings)),
+			parent:    intr.scope,
+		}
+		// The bound expressions are evaluated in the enclosing scope,
+		// the body in the new one.
+		for _, binding := range bindings {
+			current, err := intr.Execute(binding.Children[0], value)
+			if err != nil {
+				return nil, err
+			}
+			inner.variables[binding.Value.(string)] = current
+		}
+		outer := intr.scope
+		intr.scope = inner
+		result, err := intr.Execute(node.Children[len(node.Children)-1], value)
+		intr.scope = outer
+		return result, err
+	case ASTArithmeticExpression:
+		left, err := intr.Execute(node.Children[0], value)
+		if err != nil {
+			return nil, err
+		}
+		right, err := intr.Execute(node.Children[1], value)
+		if err != nil {
+			return nil, err
+		}
+		return binaryArithmetic(node.Value.(tokType), left, right)
+	case ASTArithmeticUnaryExpression:
+		operand, err := intr.Execute(node.Children[0], value)
+		if err != nil {
+			return nil, err
+		}
+		return unaryArithmetic(node.Value.(tokType), operand)
+	case ASTVariable:
+		return intr.variable(node.Value.(string))
+	}
+	return nil, errors.New("Unknown AST node: " + node.NodeType.String())
+}
+
+// compare applies the comparison operator to left and right. Ordering
+// comparisons of anything but numbers result in null.
+func compare(operator tokType, left, right interface{}) interface{} {
+	switch operator {
+	case tEQ:
+		return objsEqual(left, right)
+	case tNE:
+		return !objsEqual(left, right)
+	}
+	order, ok := compareNumbers(left, right)
+	if !ok {
+		return nil
+	}
+	switch operator {
+	case tGT:
+		return order > 0
+	case tGTE:
+		return order >= 0
+	case tLT:
+		return order < 0
+	case tLTE:
+		return order <= 0
+	}
+	return nil
+}
+
+func binaryArithmetic(operator tokType, left, right interface{}) (interface{}, error) {
+	leftNum, ok := toFloat(left)
+	if !ok {
+		return nil, arithmeticTypeError(operator, 0, left)
+	}
+	rightNum, ok := toFloat(right)
+	if !ok {
+		return nil, arithmeticTypeError(operator, 1, right)
+	}
+	return arithmetic(operator, leftNum, rightNum)
+}
+
+func unaryArithmetic(operator tokType, operand interface{}) (interface{}, error) {
+	num, ok := toFloat(operand)
+	if !ok {
+		return nil, arithmeticTypeError(operator, 0, operand)
+	}
+	if operator == tMinus {
+		return finite(operator, -num)
+	}
+	return finite(operator, num)
+}
+
+func (intr *treeInterpreter) variable(name string) (interface{}, error) {
+	if current, ok := intr.scope.lookup(name); ok {
+		return current, nil
+	}
+	return nil, &UndefinedVariableError{Name: name}
+}
+
+func (intr *treeInterpreter) field(key string, value interface{}) (interface{}, error) {
+	if m, ok := value.(map[string]interface{}); ok {
+		return m[key], nil
 	}
-	return nil, errors.New("Unknown AST node: " + node.nodeType.String())
+	return intr.fieldWithReflection(key, value)
 }
 
-func (intr *treeInterpreter) fieldFromStruct(key string, value interface{}) (interface{}, error) {
+// index returns the element of a slice at index, counting from the end
+// if index is negative, or nil if there is no such element.
+func index(value interface{}, index int) interface{} {
+	if sliceType, ok := value.([]interface{}); ok {
+		if index < 0 {
+			index += len(sliceType)
+		}
+		if index < len(sliceType) && index >= 0 {
+			return sliceType[index]
+		}
+		return nil
+	}
+	// Otherwise try via reflection.
 	rv := reflect.ValueOf(value)
-	first, n := utf8.DecodeRuneInString(key)
-	fieldName := string(unicode.ToUpper(first)) + key[n:]
-	if rv.Kind() == reflect.Struct {
-		v := rv.FieldByName(fieldName)
-		if !v.IsValid() {
-			return nil, nil
+	if rv.Kind() == reflect.Slice {
+		if index < 0 {
+			index += rv.Len()
 		}
-		return v.Interface(), nil
-	} else if rv.Kind() == reflect.Ptr {
-		// Handle multiple levels of indirection?
+		if index < rv.Len() && index >= 0 {
+			v := rv.Index(index)
+			return v.Interface()
+		}
+	}
+	return nil
+}
+
+func (intr *treeInterpreter) sliceOf(parts []*int, value interface{}) (interface{}, error) {
+	sliceType, ok := value.([]interface{})
+	if !ok {
+		if isSliceType(value) {
+			return intr.sliceWithReflection(parts, value)
+		}
+		return nil, nil
+	}
+	return slice(sliceType, sliceParams(parts))
+}
+
+func sliceParams(parts []*int) []sliceParam {
+	params := make([]sliceParam, 3)
+	for i, part := range parts {
+		if part != nil {
+			params[i].Specified = true
+			params[i].N = *part
+		}
+	}
+	return params
+}
+
+func (intr *treeInterpreter) flatten(left interface{}) (interface{}, error) {
+	sliceType, ok := left.([]interface{})
+	if !ok {
+		// If we can't type convert to []interface{}, there's
+		// a chance this could still work via reflection if we're
+		// dealing with user provided types.
+		if isSliceType(left) {
+			return intr.flattenWithReflection(left)
+		}
+		return nil, nil
+	}
+	flattened := []interface{}{}
+	for _, element := range sliceType {
+		if err := intr.checkContext(); err != nil {
+			return nil, err
+		}
+		if elementSlice, ok := element.([]interface{}); ok {
+			flattened = append(flattened, elementSlice...)
+		} else if isSliceType(element) {
+			reflectFlat := []interface{}{}
+			v := reflect.ValueOf(element)
+			for i := 0; i < v.Len(); i++ {
+				reflectFlat = append(reflectFlat, v.Index(i).Interface())
+			}
+			flattened = append(flattened, reflectFlat...)
+		} else {
+			flattened = append(flattened, element)
+		}
+		if err := intr.checkCollectionSize(len(flattened)); err != nil {
+			return nil, err
+		}
+	}
+	return flattened, nil
+}
+
+func arithmeticTypeError(operator tokType, argIndex int, operand interface{}) error {
+	return &InvalidTypeError{
+		Function: operatorSymbols[operator],
+		ArgIndex: argIndex,
+		Expected: []JpType{JpNumber},
+		Actual:   jpTypeOf(operand),
+	}
+}
+
+// arithmetic applies the binary arithmetic operator to left and right.
+func arithmetic(operator tokType, left, right float64) (interface{}, error) {
+	switch operator {
+	case tPlus:
+		return finite(operator, left+right)
+	case tMinus:
+		return finite(operator, left-right)
+	case tStar:
+		return finite(operator, left*right)
+	}
+	if right == 0 {
+		return nil, &InvalidValueError{
+			Function: operatorSymbols[operator],
+			ArgIndex: 1,
+			Reason:   "division by zero",
+		}
+	}
+	switch operator {
+	case tDivide:
+		return finite(operator, left/right)
+	case tModulo:
+		// Like integer division, the result of a modulo is floored so
+		// that it has the sign of the divisor.
+		mod := math.Mod(left, right)
+		if mod != 0 && (mod < 0) != (right < 0) {
+			mod += right
+		}
+		return finite(operator, mod)
+	case tIntegerDivide:
+		return finite(operator, math.Floor(left/right))
+	}
+	return nil, errors.New("Unknown arithmetic operator: " + operator.String())
+}
+
+// finite returns the result of an arithmetic operator, or an error if it
+// overflowed or isn't a number, as JSON only has finite numbers.
+func finite(operator tokType, result float64) (interface{}, error) {
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		return nil, &InvalidValueError{
+			Function: operatorSymbols[operator],
+			ArgIndex: 0,
+			Reason:   "the result is not a finite number",
+		}
+	}
+	return result, nil
+}
+
+// fieldWithReflection returns the value of the key in a map with string
+// keys, or the field of a struct that encoding/json would encode with the
+// key as name. Pointers to maps and structs are followed.
+func (intr *treeInterpreter) fieldWithReflection(key string, value interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr {
 		if rv.IsNil() {
 			return nil, nil
 		}
 		rv = rv.Elem()
-		v := rv.FieldByName(fieldName)
+	}
+	if rv.Kind() == reflect.Map {
+		keyType := rv.Type().Key()
+		if keyType.Kind() != reflect.String {
+			return nil, nil
+		}
+		v := rv.MapIndex(reflect.ValueOf(key).Convert(keyType))
 		if !v.IsValid() {
 			return nil, nil
 		}
 		return v.Interface(), nil
 	}
-	return nil, nil
+	if rv.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	field := cachedStructFields(rv.Type()).lookup(key)
+	if field == nil {
+		return nil, nil
+	}
+	v, ok := field.value(rv)
+	if !ok {
+		return nil, nil
+	}
+	return v.Interface(), nil
 }
 
 func (intr *treeInterpreter) flattenWithReflection(value interface{}) (interface{}, error) {
 	v := reflect.ValueOf(value)
 	flattened := []interface{}{}
 	for i := 0; i < v.Len(); i++ {
+		if err := intr.checkContext(); err != nil {
+			return nil, err
+		}
 		element := v.Index(i).Interface()
 		if reflect.TypeOf(element).Kind() == reflect.Slice {
 			// Then insert the contents of the element
@@ -356,45 +679,46 @@ func (intr *treeInterpreter) flattenWithReflection(value interface{}) (interface
 		} else {
 			flattened = append(flattened, element)
 		}
+		if err := intr.checkCollectionSize(len(flattened)); err != nil {
+			return nil, err
+		}
 	}
 	return flattened, nil
 }
 
-func (intr *treeInterpreter) sliceWithReflection(node ASTNode, value interface{}) (interface{}, error) {
+func (intr *treeInterpreter) sliceWithReflection(parts []*int, value interface{}) (interface{}, error) {
 	v := reflect.ValueOf(value)
-	parts := node.value.([]*int)
-	sliceParams := make([]sliceParam, 3)
-	for i, part := range parts {
-		if part != nil {
-			sliceParams[i].Specified = true
-			sliceParams[i].N = *part
-		}
-	}
 	final := []interface{}{}
 	for i := 0; i < v.Len(); i++ {
 		element := v.Index(i).Interface()
 		final = append(final, element)
 	}
-	return slice(final, sliceParams)
+	return slice(final, sliceParams(parts))
 }
 
 func (intr *treeInterpreter) filterProjectionWithReflection(node ASTNode, value interface{}) (interface{}, error) {
-	compareNode := node.children[2]
+	compareNode := node.Children[2]
 	collected := []interface{}{}
 	v := reflect.ValueOf(value)
 	for i := 0; i < v.Len(); i++ {
+		if err := intr.checkContext(); err != nil {
+			return nil, err
+		}
 		element := v.Index(i).Interface()
 		result, err := intr.Execute(compareNode, element)
 		if err != nil {
 			return nil, err
 		}
 		if !isFalse(result) {
-			current, err := intr.Execute(node.children[1], element)
+			current, err := intr.Execute(node.Children[1], element)
 			if err != nil {
 				return nil, err
 			}
 			if current != nil {
 				collected = append(collected, current)
+				if err := intr.checkCollectionSize(len(collected)); err != nil {
+					return nil, err
+				}
 			}
 		}
 	}
@@ -405,13 +729,19 @@ func (intr *treeInterpreter) projectWithReflection(node ASTNode, value interface
 	collected := []interface{}{}
 	v := reflect.ValueOf(value)
 	for i := 0; i < v.Len(); i++ {
+		if err := intr.checkContext(); err != nil {
+			return nil, err
+		}
 		element := v.Index(i).Interface()
-		result, err := intr.Execute(node.children[1], element)
+		result, err := intr.Execute(node.Children[1], element)
 		if err != nil {
 			return nil, err
 		}
 		if result != nil {
 			collected = append(collected, result)
+			if err := intr.checkCollectionSize(len(collected)); err != nil {
+				return nil, err
+			}
 		}
 	}
 	return collected, nil
@@ -2,6 +2,7 @@ package jmespath
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
 
 	"github.com/jmespath/go-jmespath/internal/testify/assert"
@@ -142,6 +143,171 @@ func TestCanSupportStructWithNestedPointers(t *testing.T) {
 	assert.Nil(result)
 }
 
+type taggedBase struct {
+	ID      int    `json:"id"`
+	Created string `json:"created_at,omitempty"`
+	Shadow  string `json:"name"`
+}
+
+type TaggedEmbedded struct {
+	Region string
+}
+
+type taggedStruct struct {
+	taggedBase
+	*TaggedEmbedded
+	UserID   string `json:"user_id"`
+	Name     string
+	Password string `json:"-"`
+	Dash     string `json:"-,"`
+	Invalid  string `json:"\\"`
+	internal string
+}
+
+func TestStructFieldsFollowJSONTags(t *testing.T) {
+	assert := assert.New(t)
+	data := &taggedStruct{
+		taggedBase:     taggedBase{ID: 7, Created: "today", Shadow: "hidden"},
+		TaggedEmbedded: &TaggedEmbedded{Region: "eu"},
+		UserID:         "u1",
+		Name:           "alice",
+		Password:       "secret",
+		Dash:           "dash",
+		Invalid:        "invalid",
+		internal:       "internal",
+	}
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"user_id", "u1"},
+		{"UserID", nil},
+		{"USER_ID", "u1"},
+		{"id", 7},
+		{"created_at", "today"},
+		{"Created", nil},
+		{"name", "hidden"},
+		{"Name", "alice"},
+		{"NAME", "hidden"},
+		{"region", "eu"},
+		{"Password", nil},
+		{"\"-\"", "dash"},
+		{"Invalid", "invalid"},
+		{"internal", nil},
+		{"taggedBase", nil},
+		{"TaggedEmbedded", nil},
+	}
+	for _, tt := range tests {
+		result, err := Search(tt.expression, data)
+		assert.Nil(err, tt.expression)
+		assert.Equal(tt.expected, result, tt.expression)
+	}
+
+	// Fields of nil embedded pointers are null.
+	result, err := Search("region", taggedStruct{})
+	assert.Nil(err)
+	assert.Nil(result)
+	// Multiple levels of indirection are followed.
+	result, err = Search("user_id", &data)
+	assert.Nil(err)
+	assert.Equal("u1", result)
+}
+
+type ambiguousA struct{ Value, A string }
+type ambiguousB struct{ Value, B string }
+type ambiguousTagged struct {
+	Value string `json:"Value"`
+}
+
+func TestStructFieldsAmbiguousEmbedding(t *testing.T) {
+	assert := assert.New(t)
+	data := struct {
+		ambiguousA
+		ambiguousB
+	}{ambiguousA{"a", "a"}, ambiguousB{"b", "b"}}
+	result, err := Search("[Value, A, B]", data)
+	assert.Nil(err)
+	assert.Equal([]interface{}{nil, "a", "b"}, result)
+
+	tagged := struct {
+		ambiguousA
+		ambiguousTagged
+	}{ambiguousA{"a", "a"}, ambiguousTagged{"tagged"}}
+	result, err = Search("Value", tagged)
+	assert.Nil(err)
+	assert.Equal("tagged", result)
+}
+
+type labels map[string]string
+
+type inventoryItem struct {
+	Name  string
+	Count int
+}
+
+type mapKey string
+
+func TestCanSupportArbitraryMapTypes(t *testing.T) {
+	assert := assert.New(t)
+	data := map[string]interface{}{
+		"labels":  labels{"env": "prod"},
+		"items":   map[string]*inventoryItem{"a": {"apple", 3}},
+		"keyed":   map[mapKey]int{"one": 1},
+		"pointer": &labels{"env": "dev"},
+		"empty":   map[string]string{},
+		"ints":    map[int]string{1: "one"},
+	}
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"labels.env", "prod"},
+		{"labels.missing", nil},
+		{"items.a.Name", "apple"},
+		{"keyed.one", 1},
+		{"pointer.env", "dev"},
+		{"pointer.*", []interface{}{"dev"}},
+		{"keys(pointer)", []interface{}{"env"}},
+		{"values(pointer)", []interface{}{"dev"}},
+		{"length(pointer)", 1.0},
+		{"type(pointer)", "object"},
+		{"merge(pointer)", map[string]interface{}{"env": "dev"}},
+		{"ints.one", nil},
+		{"labels.*", []interface{}{"prod"}},
+		{"items.*.Count", []interface{}{3}},
+		{"ints.*", nil},
+		{"keys(labels)", []interface{}{"env"}},
+		{"keys(keyed)", []interface{}{"one"}},
+		{"values(labels)", []interface{}{"prod"}},
+		{"merge(labels, `{\"tier\": \"web\"}`)", map[string]interface{}{"env": "prod", "tier": "web"}},
+		{"length(items)", 1.0},
+		{"length(empty)", 0.0},
+		{"type(labels)", "object"},
+		{"to_number(labels)", nil},
+		{"!empty", true},
+		{"!labels", false},
+		{"labels || 'none'", labels{"env": "prod"}},
+		{"empty || 'none'", "none"},
+		{"[labels, empty][?env].env", []interface{}{"prod"}},
+		{"labels == {env: 'prod'}", true},
+		{"pointer == `{\"env\": \"dev\"}`", true},
+		{"labels == pointer", false},
+		{"contains([labels], {env: 'prod'})", true},
+	}
+	for _, tt := range tests {
+		result, err := Search(tt.expression, data)
+		assert.Nil(err, tt.expression)
+		assert.Equal(tt.expected, result, tt.expression)
+		result, err = searchWithTreeInterpreter(tt.expression, data)
+		assert.Nil(err, tt.expression)
+		assert.Equal(tt.expected, result, tt.expression)
+	}
+
+	// Maps that don't have string keys aren't objects.
+	_, err := Search("keys(ints)", data)
+	assert.NotNil(err)
+}
+
 func TestCanSupportFlattenNestedSlice(t *testing.T) {
 	assert := assert.New(t)
 	data := nestedSlice{A: []sliceType{
@@ -232,3 +398,229 @@ func BenchmarkInterpretNestedMaps(b *testing.B) {
 		}
 	}
 }
+
+var evaluationBenchmarks = []struct {
+	name       string
+	expression string
+}{
+	{"Field", "foo"},
+	{"NestedFields", "foo.bar.baz.qux"},
+	{"Projection", "people[*].name"},
+	{"Filter", "people[?age > `30`].name"},
+	{"MultiSelect", "people[*].{name: name, older: age > `40`}"},
+	{"Functions", "sort_by(people, &age)[-1].name"},
+}
+
+func evaluationBenchmarkData(b *testing.B) interface{} {
+	people := make([]interface{}, 100)
+	for i := range people {
+		people[i] = map[string]interface{}{"name": fmt.Sprintf("person%d", i), "age": float64(i % 60)}
+	}
+	var nested interface{}
+	err := json.Unmarshal([]byte(`{"bar": {"baz": {"qux": 1}}}`), &nested)
+	assert.Nil(b, err)
+	return map[string]interface{}{"foo": nested, "people": people}
+}
+
+// BenchmarkTreeInterpreter and BenchmarkVM evaluate the same compiled
+// expressions, with the tree interpreter and with the virtual machine used
+// by Search.
+func BenchmarkTreeInterpreter(b *testing.B) {
+	data := evaluationBenchmarkData(b)
+	for _, bm := range evaluationBenchmarks {
+		compiled := MustCompile(bm.expression)
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				intr := compiled.newEvaluation()
+				intr.root = data
+				if _, err := intr.Execute(compiled.ast, data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkVM(b *testing.B) {
+	data := evaluationBenchmarkData(b)
+	for _, bm := range evaluationBenchmarks {
+		compiled := MustCompile(bm.expression)
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				intr := compiled.newEvaluation()
+				intr.root = data
+				m := vm{intr: intr}
+				if _, err := m.run(compiled.program, data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestLetExpressions(t *testing.T) {
+	assert := assert.New(t)
+	var data interface{}
+	err := json.Unmarshal([]byte(`{
+		"limit": 2,
+		"owner": "a",
+		"items": [{"n": 1, "owner": "a"}, {"n": 2, "owner": "b"}, {"n": 3, "owner": "a"}],
+		"let": "field"
+	}`), &data)
+	assert.Nil(err)
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"let $x = limit in $x", 2.0},
+		{"let $x = limit, $y = owner in [$x, $y]", []interface{}{2.0, "a"}},
+		{"let $limit = limit in items[?n > $limit].n", []interface{}{3.0}},
+		{"let $owner = owner in items[?owner == $owner].n", []interface{}{1.0, 3.0}},
+		{"let $x = `1` in let $x = `2` in $x", 2.0},
+		{"let $x = `1` in [let $x = `2` in $x, $x]", []interface{}{2.0, 1.0}},
+		{"let $x = `1`, $y = `2` in let $x = $y, $y = $x in [$x, $y]", []interface{}{2.0, 1.0}},
+		{"items[0] | let $n = n in $n", 1.0},
+		{"let $owner = owner in map(&owner == $owner, items)", []interface{}{true, false, true}},
+		{"let", "field"},
+	}
+	for _, tt := range tests {
+		result, err := Search(tt.expression, data)
+		if assert.Nil(err, tt.expression) {
+			assert.Equal(tt.expected, result, tt.expression)
+		}
+	}
+}
+
+func TestUndefinedVariable(t *testing.T) {
+	assert := assert.New(t)
+	_, err := Search("$foo", nil)
+	assert.NotNil(err)
+	_, err = Search("[let $x = `1` in $x, $x]", map[string]interface{}{})
+	assert.NotNil(err)
+}
+
+func TestArithmeticExpressions(t *testing.T) {
+	assert := assert.New(t)
+	var data interface{}
+	err := json.Unmarshal([]byte(`{
+		"a": 7, "b": 2, "c": -3,
+		"items": [{"price": 2.5, "quantity": 4}, {"price": 1, "quantity": 3}],
+		"nested": {"a": 10}
+	}`), &data)
+	assert.Nil(err)
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"a + b", 9.0},
+		{"a - b", 5.0},
+		{"a-b", 5.0},
+		{"a * b", 14.0},
+		{"a / b", 3.5},
+		{"a % b", 1.0},
+		{"a // b", 3.0},
+		{"c // b", -2.0},
+		{"c % b", 1.0},
+		{"-a", -7.0},
+		{"+a", 7.0},
+		{"- -a", 7.0},
+		{"-nested.a", -10.0},
+		{"a + b * c", 1.0},
+		{"(a + b) * c", -27.0},
+		{"a - b - c", 8.0},
+		{"a / b * b", 7.0},
+		{"a * b / b", 7.0},
+		{"-a * b", -14.0},
+		{"a + b > `8`", true},
+		{"a * `2` == `14`", true},
+		{"items[*].[price * quantity]", []interface{}{[]interface{}{10.0}, []interface{}{3.0}}},
+		{"items[?price * quantity > `5`].price", []interface{}{2.5}},
+		{"{total: items[0].price * items[0].quantity}", map[string]interface{}{"total": 10.0}},
+		{"sum(items[*].price) + a", 10.5},
+	}
+	for _, tt := range tests {
+		result, err := Search(tt.expression, data)
+		if assert.Nil(err, tt.expression) {
+			assert.Equal(tt.expected, result, tt.expression)
+		}
+	}
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	assert := assert.New(t)
+	data := map[string]interface{}{"a": 1.0, "s": "foo", "items": []interface{}{1.0}, "huge": json.Number("1e400")}
+	for _, expression := range []string{"a + s", "s * a", "-s", "a / `0`", "a % `0`", "a // `0`", "missing + a", "items[*].price * `2`",
+		"`1e308` * `10`", "-`1e308` - `1e308`", "`1e308` / `0.1`", "`1e308` // `0.1`", "-huge", "huge % `2`",
+	} {
+		_, err := Search(expression, data)
+		assert.NotNil(err, expression)
+		_, err = searchWithTreeInterpreter(expression, data)
+		assert.NotNil(err, expression)
+	}
+}
+
+func TestRootNodeReference(t *testing.T) {
+	assert := assert.New(t)
+	var data interface{}
+	err := json.Unmarshal([]byte(`{
+		"currentUser": "b",
+		"items": [{"id": 1, "owner": "a"}, {"id": 2, "owner": "b"}],
+		"nested": {"items": [{"id": 3, "owner": "b"}]}
+	}`), &data)
+	assert.Nil(err)
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"$", data},
+		{"$.currentUser", "b"},
+		{"items[?owner == $.currentUser].id", []interface{}{2.0}},
+		{"nested.items[?owner == $.currentUser].id", []interface{}{3.0}},
+		{"nested | items[*].[id, $.currentUser]", []interface{}{[]interface{}{3.0, "b"}}},
+		{"map(&$.currentUser, items)", []interface{}{"b", "b"}},
+		{"let $user = $.currentUser in nested.items[?owner == $user].id", []interface{}{3.0}},
+	}
+	for _, tt := range tests {
+		result, err := Search(tt.expression, data)
+		if assert.Nil(err, tt.expression) {
+			assert.Equal(tt.expected, result, tt.expression)
+		}
+	}
+}
+
+func TestTernaryExpressions(t *testing.T) {
+	assert := assert.New(t)
+	var data interface{}
+	err := json.Unmarshal([]byte(`{
+		"t": true, "f": false, "n": null, "empty": [], "a": "a", "b": "b", "c": "c",
+		"items": [{"price": 5}, {"price": 20}]
+	}`), &data)
+	assert.Nil(err)
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"t ? a : b", "a"},
+		{"f ? a : b", "b"},
+		{"n ? a : b", "b"},
+		{"empty ? a : b", "b"},
+		{"missing ? a : b", "b"},
+		{"f ? a : t ? b : c", "b"},
+		{"f ? a : f ? b : c", "c"},
+		{"t ? f ? a : b : c", "b"},
+		{"f || t ? a : b", "a"},
+		{"t && f ? a : b", "b"},
+		{"f ? a : b || c", "b"},
+		{"t ? a : b | length(@)", 1.0},
+		{"items[*].[price > `10` ? 'high' : 'low']", []interface{}{[]interface{}{"low"}, []interface{}{"high"}}},
+		{"{x: t ? a : b}", map[string]interface{}{"x": "a"}},
+	}
+	for _, tt := range tests {
+		result, err := Search(tt.expression, data)
+		if assert.Nil(err, tt.expression) {
+			assert.Equal(tt.expected, result, tt.expression)
+		}
+	}
+}
@@ -33,6 +33,7 @@ type SyntaxError struct {
 	msg        string // Error message displayed to user
 	Expression string // Expression that generated a SyntaxError
 	Offset     int    // The location in the string where the error occurred
+	err        error  // Underlying error of an invalid function call
 }
 
 func (e SyntaxError) Error() string {
@@ -41,6 +42,12 @@ func (e SyntaxError) Error() string {
 	return "SyntaxError: " + e.msg
 }
 
+// Unwrap returns the *UnknownFunctionError or *InvalidArityError of a
+// function call rejected at compile time, or nil.
+func (e SyntaxError) Unwrap() error {
+	return e.err
+}
+
 // HighlightLocation will show where the syntax error occurred.
 // It will place a "^" character on a line below the expression
 // at the point where the syntax error occurred.
@@ -80,6 +87,15 @@ const (
 	tExpref
 	tAnd
 	tNot
+	tVariable
+	tAssign
+	tPlus
+	tMinus
+	tDivide
+	tModulo
+	tIntegerDivide
+	tRoot
+	tQuestion
 	tEOF
 )
 
@@ -94,6 +110,9 @@ var basicTokens = map[rune]tokType{
 	'(': tLparen,
 	')': tRparen,
 	'@': tCurrent,
+	'+': tPlus,
+	'%': tModulo,
+	'?': tQuestion, // "[?" is handled by consumeLBracket
 }
 
 // Bit mask for [a-zA-Z_] shifted down 64 bits to fit in a single uint64.
@@ -161,9 +180,12 @@ loop:
 				length:    1,
 			}
 			tokens = append(tokens, t)
-		} else if r == '-' || (r >= '0' && r <= '9') {
+		} else if r == '-' || isDigit(r) {
 			t := lexer.consumeNumber()
 			tokens = append(tokens, t)
+		} else if r == '/' {
+			t := lexer.matchOrElse(r, '/', tIntegerDivide, tDivide)
+			tokens = append(tokens, t)
 		} else if r == '[' {
 			t := lexer.consumeLBracket()
 			tokens = append(tokens, t)
@@ -198,7 +220,10 @@ loop:
 			t := lexer.matchOrElse(r, '=', tNE, tNot)
 			tokens = append(tokens, t)
 		} else if r == '=' {
-			t := lexer.matchOrElse(r, '=', tEQ, tUnknown)
+			t := lexer.matchOrElse(r, '=', tEQ, tAssign)
+			tokens = append(tokens, t)
+		} else if r == '$' {
+			t := lexer.consumeVariable()
 			tokens = append(tokens, t)
 		} else if r == '&' {
 			t := lexer.matchOrElse(r, '&', tAnd, tExpref)
@@ -400,9 +425,45 @@ func (lexer *Lexer) consumeUnquotedIdentifier() token {
 	}
 }
 
+func (lexer *Lexer) consumeVariable() token {
+	// A variable is a "$" immediately followed by an unquoted
+	// identifier, e.g. "$foo". A "$" on its own is the root node.
+	start := lexer.currentPos - lexer.lastWidth
+	r := lexer.peek()
+	if r < 0 || identifierStartBits&(1<<(uint64(r)-64)) == 0 {
+		return token{
+			tokenType: tRoot,
+			value:     "$",
+			position:  start,
+			length:    1,
+		}
+	}
+	lexer.next()
+	name := lexer.consumeUnquotedIdentifier()
+	return token{
+		tokenType: tVariable,
+		value:     name.value,
+		position:  start,
+		length:    lexer.currentPos - start,
+	}
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
 func (lexer *Lexer) consumeNumber() token {
 	// Consume runes until we reach something that's not a number.
 	start := lexer.currentPos - lexer.lastWidth
+	if lexer.expression[start] == '-' && !isDigit(lexer.peek()) {
+		// A "-" that doesn't start a number is the minus operator.
+		return token{
+			tokenType: tMinus,
+			value:     "-",
+			position:  start,
+			length:    1,
+		}
+	}
 	for {
 		r := lexer.next()
 		if r < '0' || r > '9' {
@@ -45,6 +45,32 @@ var lexingTests = []struct {
 	{`'foo\'bar'`, []token{{tStringLiteral, "foo'bar", 1, 7}}},
 	{"@", []token{{tCurrent, "@", 0, 1}}},
 	{"&", []token{{tExpref, "&", 0, 1}}},
+	{"=", []token{{tAssign, "=", 0, 1}}},
+	{"$foo", []token{{tVariable, "foo", 0, 4}}},
+	{"$_1", []token{{tVariable, "_1", 0, 3}}},
+	{"$", []token{{tRoot, "$", 0, 1}}},
+	{"$.foo", []token{
+		{tRoot, "$", 0, 1},
+		{tDot, ".", 1, 1},
+		{tUnquotedIdentifier, "foo", 2, 3},
+	}},
+	{"+", []token{{tPlus, "+", 0, 1}}},
+	{"-", []token{{tMinus, "-", 0, 1}}},
+	{"/", []token{{tDivide, "/", 0, 1}}},
+	{"//", []token{{tIntegerDivide, "//", 0, 2}}},
+	{"%", []token{{tModulo, "%", 0, 1}}},
+	{"?", []token{{tQuestion, "?", 0, 1}}},
+	{"a-b", []token{
+		{tUnquotedIdentifier, "a", 0, 1},
+		{tMinus, "-", 1, 1},
+		{tUnquotedIdentifier, "b", 2, 1},
+	}},
+	{"a[-1]", []token{
+		{tUnquotedIdentifier, "a", 0, 1},
+		{tLbracket, "[", 1, 1},
+		{tNumber, "-1", 2, 2},
+		{tRbracket, "]", 4, 1},
+	}},
 	// Quoted identifier unicode escape sequences
 	{`"\u2713"`, []token{{tQuotedIdentifier, "✓", 0, 3}}},
 	{`"\\"`, []token{{tQuotedIdentifier, `\`, 0, 1}}},
@@ -95,7 +121,7 @@ var lexingErrorTests = []struct {
 	msg        string
 }{
 	{"'foo", "Missing closing single quote"},
-	{"[?foo==bar?]", "Unknown char '?'"},
+	{"[?foo==bar^]", "Unknown char '^'"},
 }
 
 func TestLexingErrors(t *testing.T) {
@@ -0,0 +1,181 @@
+package jmespath
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// Limits bounds the resources used when evaluating an expression, which is
+// useful when the expression or the data come from an untrusted source.
+// A zero field means that there is no limit.
+type Limits struct {
+	// MaxSteps is the maximum number of AST nodes that are evaluated.
+	MaxSteps int
+	// MaxResultElements is the maximum number of values in the result,
+	// counting the elements of arrays and the members of objects at
+	// every level.
+	MaxResultElements int
+	// MaxDepth is the maximum nesting of AST node evaluations, including
+	// the ones made by functions evaluating an expref.
+	MaxDepth int
+	// MaxCollectionSize is the maximum length of an array or object
+	// created during the evaluation, e.g. by a projection or a function.
+	MaxCollectionSize int
+}
+
+// ErrLimitExceeded matches, using errors.Is, any of the errors returned when
+// a limit is exceeded.
+var ErrLimitExceeded = errors.New("evaluation limit exceeded")
+
+// StepLimitError is returned when an evaluation exceeds Limits.MaxSteps.
+type StepLimitError struct {
+	Limit int
+}
+
+func (e *StepLimitError) Error() string {
+	return "evaluation exceeded the maximum number of steps: " + strconv.Itoa(e.Limit)
+}
+
+// Is reports whether target is ErrLimitExceeded.
+func (e *StepLimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// ResultSizeLimitError is returned when the result of an evaluation exceeds
+// Limits.MaxResultElements.
+type ResultSizeLimitError struct {
+	Limit int
+}
+
+func (e *ResultSizeLimitError) Error() string {
+	return "result exceeded the maximum number of elements: " + strconv.Itoa(e.Limit)
+}
+
+// Is reports whether target is ErrLimitExceeded.
+func (e *ResultSizeLimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// DepthLimitError is returned when an evaluation exceeds Limits.MaxDepth.
+type DepthLimitError struct {
+	Limit int
+}
+
+func (e *DepthLimitError) Error() string {
+	return "evaluation exceeded the maximum depth: " + strconv.Itoa(e.Limit)
+}
+
+// Is reports whether target is ErrLimitExceeded.
+func (e *DepthLimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// CollectionSizeLimitError is returned when an evaluation creates an array
+// or object larger than Limits.MaxCollectionSize.
+type CollectionSizeLimitError struct {
+	Limit int
+}
+
+func (e *CollectionSizeLimitError) Error() string {
+	return "evaluation exceeded the maximum collection size: " + strconv.Itoa(e.Limit)
+}
+
+// Is reports whether target is ErrLimitExceeded.
+func (e *CollectionSizeLimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// enter accounts for the evaluation of one more node. The caller must
+// decrement intr.depth once the node has been evaluated.
+func (intr *treeInterpreter) enter() error {
+	intr.steps++
+	if intr.limits.MaxSteps > 0 && intr.steps > intr.limits.MaxSteps {
+		return &StepLimitError{Limit: intr.limits.MaxSteps}
+	}
+	if intr.limits.MaxDepth > 0 && intr.depth >= intr.limits.MaxDepth {
+		return &DepthLimitError{Limit: intr.limits.MaxDepth}
+	}
+	intr.depth++
+	return nil
+}
+
+// checkCollection verifies the size of the collections created by nodes
+// that build new arrays or objects. Collections selected from the input
+// data are not limited.
+func (intr *treeInterpreter) checkCollection(nodeType ASTNodeType, result interface{}) error {
+	if intr.limits.MaxCollectionSize <= 0 {
+		return nil
+	}
+	switch nodeType {
+	case ASTProjection, ASTFilterProjection, ASTValueProjection, ASTFlatten,
+		ASTSlice, ASTMultiSelectList, ASTMultiSelectHash, ASTFunctionExpression:
+	default:
+		return nil
+	}
+	size := 0
+	switch v := result.(type) {
+	case []interface{}:
+		size = len(v)
+	case map[string]interface{}:
+		size = len(v)
+	case nil:
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			size = rv.Len()
+		}
+	}
+	return intr.checkCollectionSize(size)
+}
+
+// checkCollectionSize verifies the size of a collection being built. It is
+// called as values are added to the collections built by projections,
+// flattens and multi-selects, so that the limit is exceeded before the
+// whole collection is built.
+func (intr *treeInterpreter) checkCollectionSize(size int) error {
+	if max := intr.limits.MaxCollectionSize; max > 0 && size > max {
+		return &CollectionSizeLimitError{Limit: max}
+	}
+	return nil
+}
+
+// checkResult verifies the number of values in the final result.
+func (intr *treeInterpreter) checkResult(result interface{}) error {
+	max := intr.limits.MaxResultElements
+	if max > 0 && countElements(result, max) > max {
+		return &ResultSizeLimitError{Limit: max}
+	}
+	return nil
+}
+
+// countElements counts the values nested in value, giving up once the
+// count exceeds max.
+func countElements(value interface{}, max int) int {
+	count := 0
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len() && count <= max; i++ {
+				count++
+				walk(v.Index(i))
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() && count <= max {
+				count++
+				walk(iter.Value())
+			}
+		}
+	}
+	walk(reflect.ValueOf(value))
+	return count
+}
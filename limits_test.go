@@ -0,0 +1,125 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+func limitsTestData(t *testing.T) interface{} {
+	var data interface{}
+	err := json.Unmarshal([]byte(`{"foo": [[1, 2, 3], [4, 5, 6], [7, 8, 9]], "bar": {"a": {"b": {"c": 1}}}}`), &data)
+	assert.Nil(t, err)
+	return data
+}
+
+func TestLimitsNotExceeded(t *testing.T) {
+	assert := assert.New(t)
+	precompiled := MustCompile("foo[].[@, @]").WithLimits(Limits{
+		MaxSteps:          100,
+		MaxResultElements: 27,
+		MaxDepth:          5,
+		MaxCollectionSize: 9,
+	})
+	result, err := precompiled.Search(limitsTestData(t))
+	assert.Nil(err)
+	assert.Len(result, 9)
+}
+
+func TestLimitsDoNotAffectOriginal(t *testing.T) {
+	assert := assert.New(t)
+	precompiled := MustCompile("foo[]")
+	_ = precompiled.WithLimits(Limits{MaxSteps: 1})
+	_, err := precompiled.Search(limitsTestData(t))
+	assert.Nil(err)
+}
+
+func TestStepLimit(t *testing.T) {
+	assert := assert.New(t)
+	_, err := MustCompile("foo[*][*]").WithLimits(Limits{MaxSteps: 10}).Search(limitsTestData(t))
+	var limitErr *StepLimitError
+	assert.True(errors.As(err, &limitErr))
+	assert.Equal(10, limitErr.Limit)
+	assert.True(errors.Is(err, ErrLimitExceeded))
+}
+
+func TestResultSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+	_, err := MustCompile("foo").WithLimits(Limits{MaxResultElements: 11}).Search(limitsTestData(t))
+	var limitErr *ResultSizeLimitError
+	assert.True(errors.As(err, &limitErr))
+	assert.True(errors.Is(err, ErrLimitExceeded))
+	_, err = MustCompile("foo").WithLimits(Limits{MaxResultElements: 12}).Search(limitsTestData(t))
+	assert.Nil(err)
+}
+
+func TestDepthLimit(t *testing.T) {
+	assert := assert.New(t)
+	_, err := MustCompile("bar.a.b.c").WithLimits(Limits{MaxDepth: 4}).Search(limitsTestData(t))
+	assert.Nil(err)
+	_, err = MustCompile("map(&map(&[[@]], @), foo)").WithLimits(Limits{MaxDepth: 4}).Search(limitsTestData(t))
+	var limitErr *DepthLimitError
+	assert.True(errors.As(err, &limitErr))
+	assert.True(errors.Is(err, ErrLimitExceeded))
+}
+
+func TestCollectionSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+	_, err := MustCompile("foo[]").WithLimits(Limits{MaxCollectionSize: 8}).Search(limitsTestData(t))
+	var limitErr *CollectionSizeLimitError
+	assert.True(errors.As(err, &limitErr))
+	assert.True(errors.Is(err, ErrLimitExceeded))
+	// Collections taken from the input are not limited.
+	_, err = MustCompile("foo[0]").WithLimits(Limits{MaxCollectionSize: 2}).Search(limitsTestData(t))
+	assert.Nil(err)
+}
+
+func TestCollectionSizeLimitStopsEarly(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	calls := 0
+	err := runtime.RegisterFunc("count", func(value interface{}) interface{} {
+		calls++
+		return value
+	})
+	assert.Nil(err)
+	err = runtime.RegisterFunction(FunctionEntry{
+		Name: "ints",
+		Handler: func([]interface{}) (interface{}, error) {
+			return []int{1, 2, 3, 4, 5}, nil
+		},
+	})
+	assert.Nil(err)
+	data := make([]interface{}, 100)
+	for i := range data {
+		data[i] = []interface{}{i, i}
+	}
+	limits := Limits{MaxCollectionSize: 3}
+	for _, expression := range []string{"[*].count(@)", "[?count(@)]", "[].count(@)"} {
+		compiled, err := runtime.Compile(expression)
+		assert.Nil(err)
+		compiled = compiled.WithLimits(limits)
+
+		// The projections stop once they have one value too many.
+		calls = 0
+		_, err = compiled.Search(data)
+		var limitErr *CollectionSizeLimitError
+		assert.True(errors.As(err, &limitErr), expression)
+		assert.True(calls <= 4, "%s: %d calls", expression, calls)
+
+		calls = 0
+		intr := compiled.newEvaluation()
+		_, err = intr.Execute(compiled.ast, data)
+		assert.True(errors.As(err, &limitErr), expression)
+		assert.True(calls <= 4, "%s: %d calls", expression, calls)
+	}
+
+	// Collections of any Go type are counted.
+	compiled, err := runtime.Compile("ints()")
+	assert.Nil(err)
+	_, err = compiled.WithLimits(limits).Search(nil)
+	var limitErr *CollectionSizeLimitError
+	assert.True(errors.As(err, &limitErr))
+}
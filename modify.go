@@ -0,0 +1,366 @@
+package jmespath
+
+// UnsupportedExpressionError is returned by Update, Set and Delete when the
+// expression selects values with anything but fields, indexes, slices,
+// wildcards, filter projections and flattens, as the values selected
+// otherwise have no location in the data that can be modified.
+type UnsupportedExpressionError struct {
+	// Expression is the unsupported part of the expression.
+	Expression string
+}
+
+func (e *UnsupportedExpressionError) Error() string {
+	return "values selected by " + e.Expression + " can't be modified"
+}
+
+// Update returns a copy of data in which each value selected by the
+// expression is replaced by the result of calling fn with it. Only the
+// arrays and objects containing replaced values are copied, the rest of
+// the copy is shared with data, which is left untouched.
+//
+// The expression may only use fields, indexes, slices, wildcards, filter
+// projections and flattens. Only the values of arrays and objects decoded
+// from JSON, []interface{} and map[string]interface{}, are modified. When
+// a field is missing from a selected object, fn is called with nil and the
+// field is added unless fn returns nil. Missing elements of arrays aren't
+// added, nor are the objects leading to the selected values.
+func (jp *JMESPath) Update(data interface{}, fn func(value interface{}) (interface{}, error)) (interface{}, error) {
+	return jp.modify(data, func(value interface{}, present bool) (interface{}, editAction, error) {
+		updated, err := fn(value)
+		if err != nil {
+			return nil, unchanged, err
+		}
+		if !present && updated == nil {
+			return nil, unchanged, nil
+		}
+		return updated, replaced, nil
+	})
+}
+
+// Set returns a copy of data in which each value selected by the
+// expression is replaced by value, as described for Update.
+func (jp *JMESPath) Set(data interface{}, value interface{}) (interface{}, error) {
+	return jp.Update(data, func(interface{}) (interface{}, error) {
+		return value, nil
+	})
+}
+
+// Delete returns a copy of data from which the values selected by the
+// expression are removed, along with the number of values removed. The
+// selected fields are removed from their objects and the selected elements
+// from their arrays, the rest of data is kept, as described for Update.
+// Deleting the whole document, with "@", results in nil.
+func (jp *JMESPath) Delete(data interface{}) (interface{}, int, error) {
+	count := 0
+	result, err := jp.modify(data, func(value interface{}, present bool) (interface{}, editAction, error) {
+		if !present {
+			return nil, unchanged, nil
+		}
+		count++
+		return nil, removed, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, count, nil
+}
+
+// Update returns a copy of data in which each value selected by a JMESPath
+// expression is replaced by the result of calling fn with it, as described
+// for JMESPath.Update.
+func Update(data interface{}, expression string, fn func(value interface{}) (interface{}, error)) (interface{}, error) {
+	jp, err := defaultCache.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return jp.Update(data, fn)
+}
+
+// Set returns a copy of data in which each value selected by a JMESPath
+// expression is replaced by value, as described for JMESPath.Update.
+func Set(data interface{}, expression string, value interface{}) (interface{}, error) {
+	jp, err := defaultCache.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return jp.Set(data, value)
+}
+
+// Delete returns a copy of data from which the values selected by a
+// JMESPath expression are removed, along with the number of values
+// removed, as described for JMESPath.Delete.
+func Delete(data interface{}, expression string) (interface{}, int, error) {
+	jp, err := defaultCache.Compile(expression)
+	if err != nil {
+		return nil, 0, err
+	}
+	return jp.Delete(data)
+}
+
+// editAction tells what an edit did to a value.
+type editAction int
+
+const (
+	unchanged editAction = iota
+	replaced
+	removed
+)
+
+// edit is applied to each value selected by an expression being modified.
+// present is false for the fields missing from the selected objects.
+type edit func(value interface{}, present bool) (interface{}, editAction, error)
+
+// modifier applies an edit to the values selected by an expression.
+type modifier struct {
+	// intr evaluates the conditions of filter projections.
+	intr *treeInterpreter
+}
+
+func (jp *JMESPath) modify(data interface{}, fn edit) (interface{}, error) {
+	if err := checkModifiable(jp.ast); err != nil {
+		return nil, err
+	}
+	intr := jp.newEvaluation()
+	intr.root = data
+	m := modifier{intr: intr}
+	result, action, err := m.modify(jp.ast, data, true, fn)
+	if err != nil {
+		return nil, err
+	}
+	switch action {
+	case unchanged:
+		return data, nil
+	case removed:
+		return nil, nil
+	}
+	return result, nil
+}
+
+// checkModifiable verifies that node only selects values that have a
+// location in the data.
+func checkModifiable(node ASTNode) error {
+	var children []ASTNode
+	switch node.NodeType {
+	case ASTIdentity, ASTCurrentNode, ASTField, ASTIndex, ASTSlice:
+	case ASTSubexpression, ASTIndexExpression, ASTValueProjection:
+		if selectsElements(node.Children[0]) {
+			// The elements are selected from an array that is
+			// built by the expression, not from the data.
+			return &UnsupportedExpressionError{Expression: node.Expression()}
+		}
+		children = node.Children
+	case ASTProjection, ASTFlatten:
+		children = node.Children
+	case ASTFilterProjection:
+		// The condition selects nothing, it can be any expression.
+		children = node.Children[:2]
+	default:
+		return &UnsupportedExpressionError{Expression: node.Expression()}
+	}
+	for _, child := range children {
+		if err := checkModifiable(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectsElements determines if node selects the elements of an array
+// rather than a single value.
+func selectsElements(node ASTNode) bool {
+	switch node.NodeType {
+	case ASTSlice, ASTFlatten, ASTProjection, ASTFilterProjection, ASTValueProjection:
+		return true
+	case ASTSubexpression, ASTIndexExpression:
+		return selectsElements(node.Children[1])
+	}
+	return false
+}
+
+// modify applies fn to the values selected by node in value, and returns
+// the new value along with what was done to it.
+func (m *modifier) modify(node ASTNode, value interface{}, present bool, fn edit) (interface{}, editAction, error) {
+	switch node.NodeType {
+	case ASTIdentity, ASTCurrentNode:
+		return fn(value, present)
+	case ASTField:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value, unchanged, nil
+		}
+		return m.editMembers(object, []string{node.Value.(string)}, fn)
+	case ASTIndex:
+		array, ok := value.([]interface{})
+		if !ok {
+			return value, unchanged, nil
+		}
+		i := node.Value.(int)
+		if i < 0 {
+			i += len(array)
+		}
+		if i < 0 || i >= len(array) {
+			return value, unchanged, nil
+		}
+		return m.editElements(array, []int{i}, fn)
+	case ASTSlice:
+		array, ok := value.([]interface{})
+		if !ok {
+			return value, unchanged, nil
+		}
+		indexes := make([]interface{}, len(array))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		selected, err := slice(indexes, sliceParams(node.Value.([]*int)))
+		if err != nil {
+			return nil, unchanged, err
+		}
+		positions := make([]int, len(selected))
+		for i, index := range selected {
+			positions[i] = index.(int)
+		}
+		return m.editElements(array, positions, fn)
+	case ASTSubexpression, ASTIndexExpression:
+		return m.modify(node.Children[0], value, present, func(left interface{}, present bool) (interface{}, editAction, error) {
+			return m.modify(node.Children[1], left, present, fn)
+		})
+	case ASTProjection, ASTFilterProjection:
+		each := func(element interface{}, present bool) (interface{}, editAction, error) {
+			if node.NodeType == ASTFilterProjection {
+				matched, err := m.intr.Execute(node.Children[2], element)
+				if err != nil {
+					return nil, unchanged, err
+				}
+				if isFalse(matched) {
+					return element, unchanged, nil
+				}
+			}
+			return m.modify(node.Children[1], element, present, fn)
+		}
+		return m.modifyElements(node.Children[0], value, present, each)
+	case ASTValueProjection:
+		return m.modify(node.Children[0], value, present, func(left interface{}, present bool) (interface{}, editAction, error) {
+			object, ok := left.(map[string]interface{})
+			if !ok {
+				return left, unchanged, nil
+			}
+			keys := make([]string, 0, len(object))
+			for key := range object {
+				keys = append(keys, key)
+			}
+			return m.editMembers(object, keys, func(member interface{}, present bool) (interface{}, editAction, error) {
+				return m.modify(node.Children[1], member, present, fn)
+			})
+		})
+	case ASTFlatten:
+		spread := func(element interface{}, present bool) (interface{}, editAction, error) {
+			if array, ok := element.([]interface{}); ok {
+				return m.editElements(array, allIndexes(array), fn)
+			}
+			return fn(element, present)
+		}
+		return m.modifyElements(node.Children[0], value, present, spread)
+	}
+	return nil, unchanged, &UnsupportedExpressionError{Expression: node.Expression()}
+}
+
+// modifyElements applies fn to each element selected by node: either the
+// elements selected by node itself, or those of the array it selects.
+func (m *modifier) modifyElements(node ASTNode, value interface{}, present bool, fn edit) (interface{}, editAction, error) {
+	if selectsElements(node) {
+		return m.modify(node, value, present, fn)
+	}
+	return m.modify(node, value, present, func(left interface{}, present bool) (interface{}, editAction, error) {
+		array, ok := left.([]interface{})
+		if !ok {
+			return left, unchanged, nil
+		}
+		return m.editElements(array, allIndexes(array), fn)
+	})
+}
+
+func allIndexes(array []interface{}) []int {
+	indexes := make([]int, len(array))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// editElements applies fn to the elements of array at indexes. The array
+// is copied if any of them is replaced or removed.
+func (m *modifier) editElements(array []interface{}, indexes []int, fn edit) (interface{}, editAction, error) {
+	var edited []interface{}
+	var remove []bool
+	for _, i := range indexes {
+		if err := m.intr.checkContext(); err != nil {
+			return nil, unchanged, err
+		}
+		value, action, err := fn(array[i], true)
+		if err != nil {
+			return nil, unchanged, err
+		}
+		if action == unchanged {
+			continue
+		}
+		if edited == nil {
+			edited = make([]interface{}, len(array))
+			copy(edited, array)
+		}
+		if action == removed {
+			if remove == nil {
+				remove = make([]bool, len(array))
+			}
+			remove[i] = true
+			continue
+		}
+		edited[i] = value
+	}
+	if edited == nil {
+		return array, unchanged, nil
+	}
+	if remove != nil {
+		kept := edited[:0]
+		for i, value := range edited {
+			if !remove[i] {
+				kept = append(kept, value)
+			}
+		}
+		edited = kept
+	}
+	return edited, replaced, nil
+}
+
+// editMembers applies fn to the members of object with the keys. The
+// object is copied if any of them is replaced or removed.
+func (m *modifier) editMembers(object map[string]interface{}, keys []string, fn edit) (interface{}, editAction, error) {
+	var edited map[string]interface{}
+	for _, key := range keys {
+		if err := m.intr.checkContext(); err != nil {
+			return nil, unchanged, err
+		}
+		member, present := object[key]
+		value, action, err := fn(member, present)
+		if err != nil {
+			return nil, unchanged, err
+		}
+		if action == unchanged || action == removed && !present {
+			continue
+		}
+		if edited == nil {
+			edited = make(map[string]interface{}, len(object))
+			for k, v := range object {
+				edited[k] = v
+			}
+		}
+		if action == removed {
+			delete(edited, key)
+		} else {
+			edited[key] = value
+		}
+	}
+	if edited == nil {
+		return object, unchanged, nil
+	}
+	return edited, replaced, nil
+}
@@ -0,0 +1,216 @@
+package jmespath
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+var setTests = []struct {
+	expression string
+	// selector selects the modified part of the document, expected is
+	// its expected value.
+	selector string
+	expected string
+}{
+	{"config.debug.level", "config", `{"debug": {"level": "set"}, "cache": {"level": 2}}`},
+	{"config.debug.enabled", "config.debug", `{"level": 1, "enabled": "set"}`},
+	{"config.missing.level", "config", `{"debug": {"level": 1}, "cache": {"level": 2}}`},
+	{"users[1].name", "users[*].name", `["a", "set", "c"]`},
+	{"users[-1].tags", "users[*].tags", `[["x", "y"], ["z"], "set"]`},
+	{"users[5].name", "length(users)", `3`},
+	{"users[*].age", "users[*].age", `["set", "set", "set"]`},
+	{"users[?age > `20`].name", "users[*].[name, age]", `[["a", 17], ["set", 42], ["set", 23]]`},
+	{"users[?disabled]", "[users[1], users[*].name]", `["set", ["a", "c"]]`},
+	{"users[::2].tags[0]", "users[*].tags", `[["set", "y"], ["z"], []]`},
+	{"users[].tags[]", "users[*].tags", `[["set", "set"], ["set"], []]`},
+	{"users[*].tags[*]", "users[*].tags", `[["set", "set"], ["set"], []]`},
+	{"config.*.level", "config", `{"debug": {"level": "set"}, "cache": {"level": "set"}}`},
+	{"matrix[]", "matrix", `[["set", "set"], "set", ["set"]]`},
+	{"matrix[0][1]", "matrix", `[[1, "set"], 3, [4]]`},
+	{"@", "@", `"set"`},
+	{"missing[*].name", "keys(@) | sort(@)", `["config", "matrix", "users"]`},
+	{"users[?age > $.config.cache.level].age", "users[*].age", `["set", "set", "set"]`},
+}
+
+func TestSet(t *testing.T) {
+	assert := assert.New(t)
+	document := `{
+		"users": [
+			{"name": "a", "age": 17, "tags": ["x", "y"]},
+			{"name": "b", "age": 42, "tags": ["z"], "disabled": true},
+			{"name": "c", "age": 23, "tags": []}
+		],
+		"config": {"debug": {"level": 1}, "cache": {"level": 2}},
+		"matrix": [[1, 2], 3, [4]]
+	}`
+	for _, tt := range setTests {
+		data := decodeJSON(t, document)
+		result, err := Set(data, tt.expression, "set")
+		if !assert.Nil(err, tt.expression) {
+			continue
+		}
+		// The data is left untouched.
+		assert.Equal(decodeJSON(t, document), data, tt.expression)
+		actual, err := Search(tt.selector, result)
+		assert.Nil(err, tt.expression)
+		assert.Equal(decodeJSON(t, tt.expected), actual, tt.expression)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	assert := assert.New(t)
+	data := decodeJSON(t, `{"users": [{"age": 17}, {"age": 42, "disabled": true}, {"age": 23}]}`)
+	calls := 0
+	result, err := MustCompile("users[*].age").Update(data, func(value interface{}) (interface{}, error) {
+		calls++
+		return value.(float64) + 1, nil
+	})
+	assert.Nil(err)
+	assert.Equal(3, calls)
+	ages, err := Search("users[*].age", result)
+	assert.Nil(err)
+	assert.Equal([]interface{}{18.0, 43.0, 24.0}, ages)
+
+	// Missing fields are only added if the update returns a value.
+	result, err = Update(data, "users[*].disabled", func(value interface{}) (interface{}, error) {
+		return value, nil
+	})
+	assert.Nil(err)
+	assert.Equal(data, result)
+	result, err = Update(data, "users[*].disabled", func(value interface{}) (interface{}, error) {
+		return value == nil, nil
+	})
+	assert.Nil(err)
+	disabled, err := Search("users[*].disabled", result)
+	assert.Nil(err)
+	assert.Equal([]interface{}{true, false, true}, disabled)
+
+	failure := errors.New("failure")
+	_, err = Update(data, "users[*].age", func(value interface{}) (interface{}, error) {
+		return nil, failure
+	})
+	assert.Equal(failure, err)
+}
+
+func TestModifyCopiesOnWrite(t *testing.T) {
+	assert := assert.New(t)
+	data := decodeJSON(t, `{
+		"users": [{"name": "a", "tags": ["x"]}, {"name": "b", "tags": ["y"]}, {"name": "c"}],
+		"config": {"debug": true}
+	}`)
+	result, err := Set(data, "users[1].name", "set")
+	assert.Nil(err)
+	same := func(expression string) bool {
+		before, _ := Search(expression, data)
+		after, _ := Search(expression, result)
+		return reflect.ValueOf(before).Pointer() == reflect.ValueOf(after).Pointer()
+	}
+	assert.True(same("config"))
+	assert.True(same("users[0]"))
+	assert.True(same("users[2]"))
+	assert.True(same("users[1].tags"))
+	assert.False(same("users"))
+	assert.False(same("users[1]"))
+	assert.False(same("@"))
+
+	// Data without any selected value is returned as it is.
+	result, err = Set(data, "users[?name == 'd'].name", "set")
+	assert.Nil(err)
+	assert.True(reflect.ValueOf(data).Pointer() == reflect.ValueOf(result).Pointer())
+}
+
+func TestModifyUnsupportedExpressions(t *testing.T) {
+	assert := assert.New(t)
+	data := decodeJSON(t, `{"users": [{"name": "a", "disabled": true}], "config": {}}`)
+	for _, expression := range []string{
+		"length(users)",
+		"users | [0]",
+		"users[?disabled] | [0]",
+		"[users, config]",
+		"{a: users}",
+		"`1`",
+		"users || config",
+		"(users[*]).name",
+		"$.users",
+		"let $u = users in $u[0]",
+		"users[0].name == 'a'",
+	} {
+		_, err := Set(data, expression, 1)
+		var unsupported *UnsupportedExpressionError
+		assert.True(errors.As(err, &unsupported), expression)
+	}
+	_, err := Set(data, "users[", 1)
+	assert.NotNil(err)
+	_, err = Set(data, "users[::0].name", 1)
+	assert.NotNil(err)
+	_, err = Set(data, "users[?abs(name)].name", 1)
+	assert.NotNil(err)
+}
+
+var deleteTests = []struct {
+	expression string
+	removed    int
+	// selector selects the modified part of the document, expected is
+	// its expected value.
+	selector string
+	expected string
+}{
+	{"users[?disabled].password", 1, "users[*].password", `["1", "3"]`},
+	{"users[*].password", 3, "users[*].password", `[]`},
+	{"users[-1]", 1, "users[*].name", `["a", "b"]`},
+	{"users[?disabled]", 1, "users[*].name", `["a", "c"]`},
+	{"items[-1]", 1, "items", `[1, 2]`},
+	{"items[?@ > `1`]", 2, "items", `[1]`},
+	{"items[1:]", 2, "items", `[1]`},
+	{"users[::2].tags[0]", 1, "users[*].tags", `[["y"], ["z"], []]`},
+	{"users[].tags[]", 3, "users[*].tags", `[[], [], []]`},
+	{"users[*].tags[?@ != 'y']", 2, "users[*].tags", `[["y"], [], []]`},
+	{"config.*.level", 2, "config", `{"debug": {}, "cache": {}}`},
+	{"config.debug", 1, "config", `{"cache": {"level": 2}}`},
+	{"matrix[]", 4, "matrix", `[[], []]`},
+	{"matrix[0][-1]", 1, "matrix", `[[1], 3, [4]]`},
+	{"users[*].missing", 0, "users[0]", `{"name": "a", "tags": ["x", "y"], "password": "1"}`},
+	{"items[5]", 0, "length(items)", `3`},
+	{"items[?@ > `9`]", 0, "length(items)", `3`},
+}
+
+func TestDelete(t *testing.T) {
+	assert := assert.New(t)
+	document := `{
+		"users": [
+			{"name": "a", "tags": ["x", "y"], "password": "1"},
+			{"name": "b", "tags": ["z"], "password": "2", "disabled": true},
+			{"name": "c", "tags": [], "password": "3"}
+		],
+		"items": [1, 2, 3],
+		"config": {"debug": {"level": 1}, "cache": {"level": 2}},
+		"matrix": [[1, 2], 3, [4]]
+	}`
+	for _, tt := range deleteTests {
+		data := decodeJSON(t, document)
+		result, removed, err := Delete(data, tt.expression)
+		if !assert.Nil(err, tt.expression) {
+			continue
+		}
+		assert.Equal(tt.removed, removed, tt.expression)
+		// The data is left untouched.
+		assert.Equal(decodeJSON(t, document), data, tt.expression)
+		actual, err := Search(tt.selector, result)
+		assert.Nil(err, tt.expression)
+		assert.Equal(decodeJSON(t, tt.expected), actual, tt.expression)
+	}
+
+	data := decodeJSON(t, document)
+	result, removed, err := MustCompile("@").Delete(data)
+	assert.Nil(err)
+	assert.Equal(1, removed)
+	assert.Nil(result)
+
+	_, removed, err = Delete(data, "length(users)")
+	var unsupported *UnsupportedExpressionError
+	assert.True(errors.As(err, &unsupported))
+	assert.Equal(0, removed)
+}
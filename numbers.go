@@ -0,0 +1,245 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// numberKind is how a number is represented while it is compared.
+type numberKind int
+
+const (
+	intNumber numberKind = iota
+	uintNumber
+	floatNumber
+)
+
+// number is a JMESPath number decoded from one of the Go types that
+// represent numbers. Integers are kept as integers, so that integers too
+// large for a float64 are compared exactly.
+type number struct {
+	kind numberKind
+	i    int64
+	u    uint64
+	f    float64
+}
+
+// toNumber decodes a number from any Go integer or floating point value,
+// including values of named types, or from a json.Number. It returns false
+// if the value isn't a number.
+func toNumber(value interface{}) (number, bool) {
+	switch v := value.(type) {
+	case float64:
+		return number{kind: floatNumber, f: v}, true
+	case int:
+		return number{kind: intNumber, i: int64(v)}, true
+	case int64:
+		return number{kind: intNumber, i: v}, true
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return number{kind: intNumber, i: i}, true
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return number{kind: uintNumber, u: u}, true
+		}
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil && !isRangeError(err) {
+			return number{}, false
+		}
+		return number{kind: floatNumber, f: f}, true
+	case nil, string, bool, []interface{}, map[string]interface{}:
+		return number{}, false
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return number{kind: intNumber, i: rv.Int()}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return number{kind: uintNumber, u: rv.Uint()}, true
+	case reflect.Float32, reflect.Float64:
+		return number{kind: floatNumber, f: rv.Float()}, true
+	}
+	return number{}, false
+}
+
+func isRangeError(err error) bool {
+	numErr, ok := err.(*strconv.NumError)
+	return ok && numErr.Err == strconv.ErrRange
+}
+
+// isNumber determines if a value is a JMESPath number.
+func isNumber(value interface{}) bool {
+	_, ok := toNumber(value)
+	return ok
+}
+
+// toFloat converts a number to a float64, which is how the results of
+// arithmetic and of numeric functions are represented. It returns false if
+// the value isn't a number.
+func toFloat(value interface{}) (float64, bool) {
+	if f, ok := value.(float64); ok {
+		return f, true
+	}
+	n, ok := toNumber(value)
+	if !ok {
+		return 0, false
+	}
+	return n.float(), true
+}
+
+func (n number) float() float64 {
+	switch n.kind {
+	case intNumber:
+		return float64(n.i)
+	case uintNumber:
+		return float64(n.u)
+	}
+	return n.f
+}
+
+// int returns the number as an int64, or the reason why it can't be one.
+func (n number) int() (int64, string) {
+	switch n.kind {
+	case intNumber:
+		return n.i, ""
+	case uintNumber:
+		if n.u > math.MaxInt64 {
+			return 0, reasonOutOfRange
+		}
+		return int64(n.u), ""
+	}
+	if n.f != math.Trunc(n.f) {
+		return 0, reasonNotAnInteger
+	}
+	if n.f < math.MinInt64 || n.f >= math.MaxInt64 {
+		return 0, reasonOutOfRange
+	}
+	return int64(n.f), ""
+}
+
+// uint returns the number as a uint64, or the reason why it can't be one.
+func (n number) uint() (uint64, string) {
+	switch n.kind {
+	case intNumber:
+		if n.i < 0 {
+			return 0, reasonOutOfRange
+		}
+		return uint64(n.i), ""
+	case uintNumber:
+		return n.u, ""
+	}
+	if n.f != math.Trunc(n.f) {
+		return 0, reasonNotAnInteger
+	}
+	if n.f < 0 || n.f >= math.MaxUint64 {
+		return 0, reasonOutOfRange
+	}
+	return uint64(n.f), ""
+}
+
+// String returns the number the way encoding/json writes it.
+func (n number) String() string {
+	switch n.kind {
+	case intNumber:
+		return strconv.FormatInt(n.i, 10)
+	case uintNumber:
+		return strconv.FormatUint(n.u, 10)
+	}
+	b, err := json.Marshal(n.f)
+	if err != nil {
+		return strconv.FormatFloat(n.f, 'g', -1, 64)
+	}
+	return string(b)
+}
+
+func (n number) bigFloat() *big.Float {
+	switch n.kind {
+	case intNumber:
+		return new(big.Float).SetInt64(n.i)
+	case uintNumber:
+		return new(big.Float).SetUint64(n.u)
+	}
+	return new(big.Float).SetFloat64(n.f)
+}
+
+// compareNumbers compares two values, returning -1, 0 or 1 when left is
+// less than, equal to or greater than right. It returns false if either
+// value isn't a number, or is NaN.
+func compareNumbers(left, right interface{}) (int, bool) {
+	if l, ok := left.(float64); ok {
+		if r, ok := right.(float64); ok {
+			return compareFloats(l, r)
+		}
+	}
+	l, ok := toNumber(left)
+	if !ok {
+		return 0, false
+	}
+	r, ok := toNumber(right)
+	if !ok {
+		return 0, false
+	}
+	return l.compare(r)
+}
+
+func compareFloats(l, r float64) (int, bool) {
+	switch {
+	case l < r:
+		return -1, true
+	case l > r:
+		return 1, true
+	case l == r:
+		return 0, true
+	}
+	return 0, false
+}
+
+func (n number) compare(other number) (int, bool) {
+	switch {
+	case n.kind == intNumber && other.kind == intNumber:
+		return compareInts(n.i, other.i), true
+	case n.kind == uintNumber && other.kind == uintNumber:
+		return compareUints(n.u, other.u), true
+	case n.kind == intNumber && other.kind == uintNumber:
+		if n.i < 0 {
+			return -1, true
+		}
+		return compareUints(uint64(n.i), other.u), true
+	case n.kind == uintNumber && other.kind == intNumber:
+		if other.i < 0 {
+			return 1, true
+		}
+		return compareUints(n.u, uint64(other.i)), true
+	case n.kind == floatNumber && other.kind == floatNumber:
+		return compareFloats(n.f, other.f)
+	}
+	// An integer and a float are compared exactly, as neither can
+	// always be converted to the other.
+	if math.IsNaN(n.f) || math.IsNaN(other.f) {
+		return 0, false
+	}
+	return n.bigFloat().Cmp(other.bigFloat()), true
+}
+
+func compareInts(l, r int64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
+
+func compareUints(l, r uint64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
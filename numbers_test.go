@@ -0,0 +1,148 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+type celsius int16
+
+func TestToNumber(t *testing.T) {
+	assert := assert.New(t)
+	for _, value := range []interface{}{
+		1.5, float32(1.5), 3, int8(-3), int16(3), int32(3), int64(3),
+		uint(3), uint8(3), uint16(3), uint32(3), uint64(3), uintptr(3),
+		celsius(20), json.Number("3"), json.Number("-1.5e3"), json.Number("18446744073709551615"),
+	} {
+		assert.True(isNumber(value), "%#v", value)
+		assert.Equal(JpNumber, jpTypeOf(value), "%#v", value)
+	}
+	for _, value := range []interface{}{nil, "3", true, []interface{}{1.0}, json.Number("x"), complex(1, 2)} {
+		assert.False(isNumber(value), "%#v", value)
+	}
+	f, ok := toFloat(json.Number("1e400"))
+	assert.True(ok)
+	assert.True(math.IsInf(f, 1))
+}
+
+func TestCompareNumbers(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		left, right interface{}
+		expected    int
+	}{
+		{1.0, 2.0, -1},
+		{2, 1.5, 1},
+		{int64(1 << 62), float64(1 << 62), 0},
+		{int64(1<<62 + 1), float64(1 << 62), 1},
+		{int64(1<<62 + 1), int64(1 << 62), 1},
+		{uint64(math.MaxUint64), int64(-1), 1},
+		{int64(-1), uint64(0), -1},
+		{uint64(math.MaxUint64), uint64(math.MaxUint64 - 1), 1},
+		{json.Number("9007199254740993"), json.Number("9007199254740992"), 1},
+		{json.Number("9007199254740993"), 9007199254740992.0, 1},
+		{json.Number("1.0"), 1, 0},
+		{celsius(-5), uint8(0), -1},
+		{float32(0.5), 0.5, 0},
+		{math.Inf(-1), int64(math.MinInt64), -1},
+	}
+	for _, tt := range tests {
+		order, ok := compareNumbers(tt.left, tt.right)
+		assert.True(ok, "%#v %#v", tt.left, tt.right)
+		assert.Equal(tt.expected, order, "%#v %#v", tt.left, tt.right)
+		order, ok = compareNumbers(tt.right, tt.left)
+		assert.True(ok, "%#v %#v", tt.right, tt.left)
+		assert.Equal(-tt.expected, order, "%#v %#v", tt.right, tt.left)
+	}
+	_, ok := compareNumbers(math.NaN(), 1)
+	assert.False(ok)
+	_, ok = compareNumbers("1", 1)
+	assert.False(ok)
+}
+
+func TestSearchNumericTypes(t *testing.T) {
+	assert := assert.New(t)
+	decoder := json.NewDecoder(strings.NewReader(`{"big": [9007199254740993, 9007199254740992, 1.5], "small": 2}`))
+	decoder.UseNumber()
+	var decoded interface{}
+	assert.Nil(decoder.Decode(&decoded))
+	data := map[string]interface{}{
+		"ints":    []interface{}{3, int64(-1), uint8(2), celsius(7)},
+		"mixed":   []interface{}{2, 1.5, json.Number("3")},
+		"items":   []interface{}{map[string]interface{}{"n": uint32(2)}, map[string]interface{}{"n": int8(1)}},
+		"decoded": decoded,
+		"count":   int64(3),
+		"typed": map[string]interface{}{
+			"ints":    []int{3, -1, 2},
+			"int64s":  []int64{4, 2},
+			"floats":  []float32{1.5, 0.5},
+			"celsius": []celsius{7, 3},
+			"strings": []string{"b", "c", "a"},
+		},
+	}
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"count == `3`", true},
+		{"count != `3.5`", true},
+		{"count > `2`", true},
+		{"ints[?@ > `2`]", []interface{}{3, celsius(7)}},
+		{"max(ints)", celsius(7)},
+		{"min(ints)", int64(-1)},
+		{"sort(ints)", []interface{}{int64(-1), uint8(2), 3, celsius(7)}},
+		{"sum(ints)", 11.0},
+		{"avg(mixed)", 6.5 / 3},
+		{"abs(ints[1])", 1.0},
+		{"ceil(mixed[2])", 3.0},
+		{"floor(count)", 3.0},
+		{"count + `1`", 4.0},
+		{"-ints[0]", -3.0},
+		{"type(ints[3])", "number"},
+		{"to_number(count)", int64(3)},
+		{"to_string(decoded.small)", "2"},
+		{"contains(ints, `3`)", true},
+		{"[`3`, `-1`, `2`, `7`] == ints", true},
+		{"sort_by(items, &n)[*].n", []interface{}{int8(1), uint32(2)}},
+		{"max_by(items, &n).n", uint32(2)},
+		{"min_by(items, &n).n", int8(1)},
+		{"max(decoded.big)", json.Number("9007199254740993")},
+		{"decoded.big[0] > decoded.big[1]", true},
+		{"decoded.big[0] == decoded.big[1]", false},
+		{"sort(decoded.big)", []interface{}{json.Number("1.5"), json.Number("9007199254740992"), json.Number("9007199254740993")}},
+		{"decoded.small == `2`", true},
+		{"sum(typed.ints)", 4.0},
+		{"avg(typed.int64s)", 3.0},
+		{"max(typed.floats)", float32(1.5)},
+		{"min(typed.celsius)", celsius(3)},
+		{"sort(typed.ints)", []interface{}{-1, 2, 3}},
+		{"max(typed.strings)", "c"},
+		{"sort(typed.strings)", []interface{}{"a", "b", "c"}},
+		{"contains(typed.int64s, `2`)", true},
+		{"join(',', typed.strings)", "b,c,a"},
+		{"sort_by(typed.ints, &@)", []interface{}{-1, 2, 3}},
+		{"typed.ints == [`3`, `-1`, `2`]", true},
+	}
+	for _, tt := range tests {
+		result, err := Search(tt.expression, data)
+		assert.Nil(err, tt.expression)
+		assert.Equal(tt.expected, result, tt.expression)
+		result, err = searchWithTreeInterpreter(tt.expression, data)
+		assert.Nil(err, tt.expression)
+		assert.Equal(tt.expected, result, tt.expression)
+	}
+}
+
+func TestSumAddsIntegersExactly(t *testing.T) {
+	assert := assert.New(t)
+	result, err := Search("sum(@)", []interface{}{int64(1 << 53), 1, 1})
+	assert.Nil(err)
+	assert.Equal(float64(1<<53+2), result)
+	result, err = Search("sum(@)", []interface{}{int64(math.MaxInt64), 1, 0.5})
+	assert.Nil(err)
+	assert.Equal(float64(math.MaxInt64)+1.5, result)
+}
@@ -0,0 +1,195 @@
+package jmespath
+
+import "encoding/json"
+
+// optimizer rewrites an AST into an equivalent one that is cheaper to
+// evaluate. Subtrees that evaluate to the same value whatever the input
+// are replaced by literals, chains of pipes and subexpressions are
+// flattened, and a filter projection piped into [0] is replaced by an
+// ASTFirstMatch.
+type optimizer struct {
+	fCall *functionCaller
+	// fold is set when constant subtrees are replaced by literals.
+	fold bool
+}
+
+// foldLimits bounds the evaluation of each constant subtree. Subtrees
+// exceeding them are left as they are, to be evaluated by each search.
+var foldLimits = Limits{MaxSteps: 10000, MaxCollectionSize: 10000}
+
+// optimize returns the optimized version of node. The node itself is left
+// untouched. Constant subtrees are only replaced by their value if fold
+// is set; their evaluation isn't then done by the searches, so it isn't
+// bounded by the limits or the context of the searches.
+func optimize(node ASTNode, fCall *functionCaller, fold bool) ASTNode {
+	o := optimizer{fCall: fCall, fold: fold}
+	return o.optimize(node)
+}
+
+func (o *optimizer) optimize(node ASTNode) ASTNode {
+	if len(node.Children) > 0 {
+		children := make([]ASTNode, len(node.Children))
+		for i, child := range node.Children {
+			children[i] = o.optimize(child)
+		}
+		node.Children = children
+	}
+	if o.fold && o.constant(node) {
+		intr := &treeInterpreter{fCall: o.fCall, limits: foldLimits}
+		// Expressions that fail are left as they are, so that the error
+		// is still returned by the search, and so are the results that
+		// can't be written as a literal, such as infinite numbers.
+		if result, err := intr.Execute(node, nil); err == nil && encodable(result) {
+			return ASTNode{NodeType: ASTLiteral, Value: result, Span: node.Span}
+		}
+	}
+	switch node.NodeType {
+	case ASTPipe, ASTSubexpression:
+		return o.collapse(node)
+	}
+	return node
+}
+
+// encodable reports whether value can be encoded to JSON.
+func encodable(value interface{}) bool {
+	_, err := json.Marshal(value)
+	return err == nil
+}
+
+// constant reports whether node, whose children are already optimized,
+// evaluates to the same value whatever the input, and is not already a
+// literal.
+func (o *optimizer) constant(node ASTNode) bool {
+	switch node.NodeType {
+	case ASTComparator, ASTArithmeticExpression, ASTArithmeticUnaryExpression,
+		ASTNotExpression, ASTAndExpression, ASTOrExpression, ASTTernaryExpression:
+		for _, child := range node.Children {
+			if child.NodeType != ASTLiteral {
+				return false
+			}
+		}
+		return true
+	case ASTFunctionExpression:
+		entry, ok := o.fCall.functionTable[node.Value.(string)]
+		if !ok || !entry.builtin {
+			return false
+		}
+		for _, arg := range node.Children {
+			if arg.NodeType == ASTExpRef && entry.hasExpRef && o.local(arg) {
+				continue
+			}
+			if arg.NodeType != ASTLiteral {
+				return false
+			}
+		}
+		return true
+	case ASTSubexpression, ASTIndexExpression, ASTPipe, ASTProjection,
+		ASTValueProjection, ASTFilterProjection, ASTFirstMatch, ASTFlatten:
+		// The other children are evaluated against the result of the
+		// first one.
+		if node.Children[0].NodeType != ASTLiteral {
+			return false
+		}
+		for _, child := range node.Children[1:] {
+			if !o.local(child) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// local reports whether the result of node only depends on the value it
+// is evaluated against, and not on the root, variables or functions that
+// are not builtin.
+func (o *optimizer) local(node ASTNode) bool {
+	switch node.NodeType {
+	case ASTRootNode, ASTVariable, ASTLetExpression:
+		return false
+	case ASTFunctionExpression:
+		if entry, ok := o.fCall.functionTable[node.Value.(string)]; !ok || !entry.builtin {
+			return false
+		}
+	}
+	for _, child := range node.Children {
+		if !o.local(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// chainLink is one of the expressions of a chain of pipes and
+// subexpressions, along with the type of the node joining it to the
+// expressions before it.
+type chainLink struct {
+	join ASTNodeType
+	node ASTNode
+}
+
+// collapse flattens nested pipes and subexpressions into a single chain,
+// which is rebuilt from left to right. Evaluating a chain is the same
+// whatever the grouping, and a chain of fields is then a single
+// instruction for the virtual machine. The current node is dropped from
+// the chain, and a filter projection followed by [0] becomes an
+// ASTFirstMatch.
+func (o *optimizer) collapse(node ASTNode) ASTNode {
+	var links []chainLink
+	// A pipe stops projections, it is kept when the expression after it
+	// is dropped.
+	pipe := false
+	for _, link := range flattenChain(node, ASTEmpty) {
+		if link.node.NodeType == ASTCurrentNode || link.node.NodeType == ASTIdentity {
+			pipe = pipe || link.join == ASTPipe
+			continue
+		}
+		if len(links) == 0 {
+			link.join = ASTEmpty
+		} else if pipe {
+			link.join = ASTPipe
+		}
+		pipe = false
+		last := len(links) - 1
+		if last >= 0 && link.join == ASTPipe && isFirstIndex(link.node) &&
+			links[last].node.NodeType == ASTFilterProjection {
+			first := links[last].node
+			first.NodeType = ASTFirstMatch
+			first.Span.End = link.node.Span.End
+			links[last].node = first
+			continue
+		}
+		links = append(links, link)
+	}
+	if len(links) == 0 {
+		return ASTNode{NodeType: ASTCurrentNode, Span: node.Span}
+	}
+	result := links[0].node
+	for _, link := range links[1:] {
+		result = ASTNode{
+			NodeType: link.join,
+			Children: []ASTNode{result, link.node},
+			Span:     Span{Start: result.Span.Start, End: link.node.Span.End},
+		}
+	}
+	return result
+}
+
+// flattenChain returns the expressions of a chain of pipes and
+// subexpressions, the first one being joined by join.
+func flattenChain(node ASTNode, join ASTNodeType) []chainLink {
+	if node.NodeType != ASTPipe && node.NodeType != ASTSubexpression {
+		return []chainLink{{join: join, node: node}}
+	}
+	links := flattenChain(node.Children[0], join)
+	return append(links, flattenChain(node.Children[1], node.NodeType)...)
+}
+
+// isFirstIndex reports whether node is the expression [0].
+func isFirstIndex(node ASTNode) bool {
+	if node.NodeType != ASTIndexExpression || node.Children[0].NodeType != ASTIdentity {
+		return false
+	}
+	index := node.Children[1]
+	return index.NodeType == ASTIndex && index.Value.(int) == 0
+}
@@ -0,0 +1,221 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+var optimizerTests = []struct {
+	expression string
+	expected   string
+}{
+	{"`1` < `2`", "`true`"},
+	{"`1` + `2` * `3`", "`7`"},
+	{"!`false` && 'a'", "'a'"},
+	{"`true` ? 'yes' : 'no'", "'yes'"},
+	{"length('abc')", "`3`"},
+	{"sort_by(`[{\"a\": 2}, {\"a\": 1}]`, &a)[0].a", "`1`"},
+	{"`{\"a\": {\"b\": [1, 2]}}`.a.b[*]", "`[1,2]`"},
+	{"foo[?bar == `1` + `1`]", "foo[?bar == `2`]"},
+	{"foo | bar | baz", "foo | bar | baz"},
+	{"foo | (bar | baz)", "foo | bar | baz"},
+	{"@ | foo | @", "foo"},
+	{"foo[*] | @ | bar", "foo[*] | bar"},
+	{"foo[?bar] | [0]", "foo[?bar] | [0]"},
+	{"foo[?bar].baz | [0].qux", "(foo[?bar].baz | [0]).qux"},
+	{"foo | [?bar] | [0]", "foo | ([?bar] | [0])"},
+	// Expressions depending on the data, or that fail, are not folded.
+	{"$.foo == `1`", "$.foo == `1`"},
+	{"let $x = `1` in $x == `1`", "let $x = `1` in $x == `1`"},
+	{"abs('a')", "abs('a')"},
+	{"`1` / `0`", "`1` / `0`"},
+	{"`1e308` * `10`", "`1e+308` * `10`"},
+	{"sum(`[1e308, 1e308]`)", "sum(`[1e+308,1e+308]`)"},
+	{"{a: `1`}", "{a: `1`}"},
+	{"foo[?bar] | [1]", "foo[?bar] | [1]"},
+	{"foo[?bar][0]", "foo[?bar][0]"},
+}
+
+func TestOptimizer(t *testing.T) {
+	assert := assert.New(t)
+	for _, tt := range optimizerTests {
+		compiled, err := Compile(tt.expression)
+		if assert.Nil(err, tt.expression) {
+			assert.Equal(tt.expected, compiled.OptimizedAST().Expression(), tt.expression)
+		}
+	}
+}
+
+func TestOptimizerFoldsUnderLimits(t *testing.T) {
+	assert := assert.New(t)
+	compiled := MustCompile("`1` + `2`")
+	assert.Equal("`3`", compiled.OptimizedAST().Expression())
+
+	// Expressions with limits evaluate their constant parts when searched.
+	limited := compiled.WithLimits(Limits{MaxSteps: 1})
+	assert.Equal("`1` + `2`", limited.OptimizedAST().Expression())
+	_, err := limited.Search(nil)
+	var limitErr *StepLimitError
+	assert.True(errors.As(err, &limitErr))
+	result, err := limited.WithLimits(Limits{}).Search(nil)
+	assert.Nil(err)
+	assert.Equal(3.0, result)
+
+	// Constant parts too costly to evaluate at compile time are left to
+	// the searches.
+	zeros := make([]int, foldLimits.MaxSteps)
+	encoded, err := json.Marshal(zeros)
+	assert.Nil(err)
+	compiled = MustCompile("`" + string(encoded) + "`[*].to_string(@)")
+	assert.Equal(ASTProjection, compiled.OptimizedAST().NodeType)
+	result, err = compiled.Search(nil)
+	assert.Nil(err)
+	assert.Len(result, len(zeros))
+}
+
+func TestOptimizerFirstMatch(t *testing.T) {
+	assert := assert.New(t)
+	ast := MustCompile("foo[?bar == `1`].baz | [0]").OptimizedAST()
+	assert.Equal(ASTFirstMatch, ast.NodeType)
+	assert.Equal(Span{Start: 0, End: 26}, ast.Span)
+
+	var data interface{}
+	err := json.Unmarshal([]byte(`{"foo": [{"bar": 1}, {"bar": 1, "baz": "a"}, {"bar": 1, "baz": "b"}]}`), &data)
+	assert.Nil(err)
+	result, err := MustCompile("foo[?bar == `1`].baz | [0]").Search(data)
+	assert.Nil(err)
+	assert.Equal("a", result)
+	result, err = MustCompile("foo[?bar == `2`] | [0]").Search(data)
+	assert.Nil(err)
+	assert.Nil(result)
+	result, err = MustCompile("bar[?baz] | [0]").Search(data)
+	assert.Nil(err)
+	assert.Nil(result)
+}
+
+func TestOptimizerFirstMatchStopsScanning(t *testing.T) {
+	assert := assert.New(t)
+	var data interface{}
+	err := json.Unmarshal([]byte(`[{"a": -1}, {"a": "not a number"}]`), &data)
+	assert.Nil(err)
+	compiled := MustCompile("[?abs(a) == `1`] | [0]")
+	result, err := compiled.Search(data)
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"a": -1.0}, result)
+}
+
+func TestOptimizerFirstMatchSteps(t *testing.T) {
+	assert := assert.New(t)
+	items := make([]interface{}, 100)
+	for i := range items {
+		items[i] = map[string]interface{}{"a": 1.0}
+	}
+	steps := func(expression string) int {
+		compiled := MustCompile(expression).WithLimits(Limits{MaxSteps: 10000})
+		intr := compiled.newEvaluation()
+		_, err := compiled.evaluate(intr, items)
+		assert.Nil(err)
+		return intr.steps
+	}
+	assert.Equal(6, steps("[?a == `1`] | [0]"))
+	assert.Equal(406, steps("[?a == `1`] | [1]"))
+}
+
+func TestOptimizerCollapsesFieldChains(t *testing.T) {
+	assert := assert.New(t)
+	compiled := MustCompile("foo.bar | baz.qux")
+	assert.Equal([]instruction{{op: opFields, value: []string{"foo", "bar", "baz", "qux"}}}, compiled.program.code)
+}
+
+func TestOptimizerKeepsCustomFunctions(t *testing.T) {
+	assert := assert.New(t)
+	runtime := NewRuntime()
+	calls := 0
+	err := runtime.RegisterFunction(FunctionEntry{
+		Name:      "next_id",
+		Arguments: []ArgSpec{{Types: []JpType{JpNumber}}},
+		Handler: func(arguments []interface{}) (interface{}, error) {
+			calls++
+			return arguments[0].(float64) + float64(calls), nil
+		},
+	})
+	assert.Nil(err)
+	compiled, err := runtime.Compile("next_id(`1`)")
+	assert.Nil(err)
+	assert.Equal(ASTFunctionExpression, compiled.OptimizedAST().NodeType)
+	result, err := compiled.Search(nil)
+	assert.Nil(err)
+	assert.Equal(2.0, result)
+	result, err = compiled.Search(nil)
+	assert.Nil(err)
+	assert.Equal(3.0, result)
+
+	// Builtins replaced by a custom function are not folded either.
+	err = runtime.RegisterFunction(FunctionEntry{
+		Name:      "abs",
+		Arguments: []ArgSpec{{Types: []JpType{JpNumber}}},
+		Handler: func(arguments []interface{}) (interface{}, error) {
+			return nil, errors.New("abs is disabled")
+		},
+	})
+	assert.Nil(err)
+	compiled, err = runtime.Compile("abs(`-1`)")
+	assert.Nil(err)
+	_, err = compiled.Search(nil)
+	assert.NotNil(err)
+}
+
+func TestOptimizerPreservesComplianceResults(t *testing.T) {
+	assert := assert.New(t)
+	files, err := filepath.Glob("compliance/*.json")
+	assert.Nil(err)
+	parser := NewParser()
+	for _, filename := range files {
+		data, err := ioutil.ReadFile(filename)
+		if !assert.Nil(err) {
+			continue
+		}
+		var suites []TestSuite
+		if !assert.Nil(json.Unmarshal(data, &suites), filename) {
+			continue
+		}
+		for _, suite := range suites {
+			for _, testcase := range suite.TestCases {
+				parsed, err := parser.Parse(testcase.Expression)
+				if err != nil {
+					continue
+				}
+				optimized := optimize(parsed, newFunctionCaller(), true)
+				// sort_by sorts its input in place, each evaluation
+				// gets its own copy of the data.
+				evaluate := func(node ASTNode) (interface{}, error) {
+					var given interface{}
+					encoded, err := json.Marshal(suite.Given)
+					assert.Nil(err)
+					assert.Nil(json.Unmarshal(encoded, &given))
+					intr := newInterpreter()
+					intr.root = given
+					return intr.Execute(node, given)
+				}
+				expected, expectedErr := evaluate(parsed)
+				actual, actualErr := evaluate(optimized)
+				assert.Equal(expectedErr, actualErr, "%s: %s", filename, testcase.Expression)
+				assert.Equal(expected, actual, "%s: %s", filename, testcase.Expression)
+
+				// The optimized AST prints as an expression that is
+				// optimized into the same AST.
+				printed := optimized.Expression()
+				reparsed, err := parser.Parse(printed)
+				if assert.Nil(err, "%s: %s printed as %s", filename, testcase.Expression, printed) {
+					reoptimized := optimize(reparsed, newFunctionCaller(), true)
+					assert.Equal(withoutSpans(optimized), withoutSpans(reoptimized), "%s: %s printed as %s", filename, testcase.Expression, printed)
+				}
+			}
+		}
+	}
+}
@@ -7,11 +7,11 @@ import (
 	"strings"
 )
 
-type astNodeType int
+type ASTNodeType int
 
-//go:generate stringer -type astNodeType
+//go:generate stringer -type ASTNodeType
 const (
-	ASTEmpty astNodeType = iota
+	ASTEmpty ASTNodeType = iota
 	ASTComparator
 	ASTCurrentNode
 	ASTExpRef
@@ -34,13 +34,81 @@ const (
 	ASTSubexpression
 	ASTSlice
 	ASTValueProjection
+	ASTLetExpression
+	ASTVariableBinding
+	ASTVariable
+	ASTArithmeticExpression
+	ASTArithmeticUnaryExpression
+	ASTRootNode
+	ASTTernaryExpression
+	ASTFirstMatch
 )
 
 // ASTNode represents the abstract syntax tree of a JMESPath expression.
+//
+// The Value of a node depends on its type: the name of an ASTField,
+// ASTFunctionExpression, ASTKeyValPair, ASTVariableBinding or ASTVariable
+// node, the decoded value of an ASTLiteral, the int of an ASTIndex, and the
+// three []*int parts of an ASTSlice, nil for parts that are omitted. Use
+// Operator for the operator of ASTComparator, ASTArithmeticExpression and
+// ASTArithmeticUnaryExpression nodes.
+//
+// ASTFirstMatch nodes are never produced by the parser. Compile replaces
+// a filter projection piped into [0] by one, with the children of the
+// filter projection, so that the search stops at the first match.
 type ASTNode struct {
-	nodeType astNodeType
-	value    interface{}
-	children []ASTNode
+	NodeType ASTNodeType
+	Value    interface{}
+	Children []ASTNode
+	// Span is the location of the node in the parsed expression. It is
+	// empty for nodes that are implied rather than written, such as the
+	// identity on the right hand side of a projection.
+	Span Span
+}
+
+// Span is a range of byte offsets in an expression. End is exclusive.
+type Span struct {
+	Start int
+	End   int
+}
+
+// operatorSymbols maps the tokens of comparison and arithmetic operators
+// to their symbols.
+var operatorSymbols = map[tokType]string{
+	tEQ:            "==",
+	tNE:            "!=",
+	tLT:            "<",
+	tLTE:           "<=",
+	tGT:            ">",
+	tGTE:           ">=",
+	tPlus:          "+",
+	tMinus:         "-",
+	tStar:          "*",
+	tDivide:        "/",
+	tModulo:        "%",
+	tIntegerDivide: "//",
+}
+
+// Operator returns the symbol of the operator of an ASTComparator,
+// ASTArithmeticExpression or ASTArithmeticUnaryExpression node, such as
+// "==" or "+". It returns an empty string for other nodes.
+func (node ASTNode) Operator() string {
+	if operator, ok := node.Value.(tokType); ok {
+		return operatorSymbols[operator]
+	}
+	return ""
+}
+
+// copy returns a deep copy of the node and its children.
+func (node ASTNode) copy() ASTNode {
+	if node.Children != nil {
+		children := make([]ASTNode, len(node.Children))
+		for i, child := range node.Children {
+			children[i] = child.copy()
+		}
+		node.Children = children
+	}
+	return node
 }
 
 func (node ASTNode) String() string {
@@ -48,28 +116,27 @@ func (node ASTNode) String() string {
 }
 
 // PrettyPrint will pretty print the parsed AST.
-// The AST is an implementation detail and this pretty print
-// function is provided as a convenience method to help with
-// debugging.  You should not rely on its output as the internal
-// structure of the AST may change at any time.
+// This pretty print function is provided as a convenience
+// method to help with debugging.  You should not rely on its
+// output as its format may change at any time.
 func (node ASTNode) PrettyPrint(indent int) string {
 	spaces := strings.Repeat(" ", indent)
-	output := fmt.Sprintf("%s%s {\n", spaces, node.nodeType)
+	output := fmt.Sprintf("%s%s {\n", spaces, node.NodeType)
 	nextIndent := indent + 2
-	if node.value != nil {
-		if converted, ok := node.value.(fmt.Stringer); ok {
+	if node.Value != nil {
+		if converted, ok := node.Value.(fmt.Stringer); ok {
 			// Account for things like comparator nodes
 			// that are enums with a String() method.
 			output += fmt.Sprintf("%svalue: %s\n", strings.Repeat(" ", nextIndent), converted.String())
 		} else {
-			output += fmt.Sprintf("%svalue: %#v\n", strings.Repeat(" ", nextIndent), node.value)
+			output += fmt.Sprintf("%svalue: %#v\n", strings.Repeat(" ", nextIndent), node.Value)
 		}
 	}
-	lastIndex := len(node.children)
+	lastIndex := len(node.Children)
 	if lastIndex > 0 {
 		output += fmt.Sprintf("%schildren: {\n", strings.Repeat(" ", nextIndent))
 		childIndent := nextIndent + 2
-		for _, elem := range node.children {
+		for _, elem := range node.Children {
 			output += elem.PrettyPrint(childIndent)
 		}
 	}
@@ -87,17 +154,26 @@ var bindingPowers = map[tokType]int{
 	tRbrace:             0,
 	tNumber:             0,
 	tCurrent:            0,
+	tRoot:               0,
 	tExpref:             0,
 	tColon:              0,
+	tVariable:           0,
+	tAssign:             0,
 	tPipe:               1,
-	tOr:                 2,
-	tAnd:                3,
+	tQuestion:           2,
+	tOr:                 3,
+	tAnd:                4,
 	tEQ:                 5,
 	tLT:                 5,
 	tLTE:                5,
 	tGT:                 5,
 	tGTE:                5,
 	tNE:                 5,
+	tPlus:               6,
+	tMinus:              6,
+	tDivide:             7,
+	tModulo:             7,
+	tIntegerDivide:      7,
 	tFlatten:            9,
 	tStar:               20,
 	tFilter:             21,
@@ -108,6 +184,25 @@ var bindingPowers = map[tokType]int{
 	tLparen:             60,
 }
 
+// A "*" following an expression is the multiplication operator rather
+// than a wildcard, with the same binding power as the other
+// multiplicative operators.
+const multiplyBindingPower = 7
+
+// The binding power of the unary "-" and "+" operators. It is higher
+// than the binary arithmetic operators but lower than flatten and
+// subexpressions, so "-a.b" negates "a.b".
+const unaryBindingPower = 8
+
+// ledBindingPower returns the binding power of a token following an
+// expression.
+func ledBindingPower(tokenType tokType) int {
+	if tokenType == tStar {
+		return multiplyBindingPower
+	}
+	return bindingPowers[tokenType]
+}
+
 // Parser holds state about the current expression being parsed.
 type Parser struct {
 	expression string
@@ -145,41 +240,65 @@ func (p *Parser) Parse(expression string) (ASTNode, error) {
 func (p *Parser) parseExpression(bindingPower int) (ASTNode, error) {
 	var err error
 	leftToken := p.lookaheadToken(0)
+	start := tokenStart(leftToken)
 	p.advance()
 	leftNode, err := p.nud(leftToken)
 	if err != nil {
 		return ASTNode{}, err
 	}
+	leftNode.Span = p.spanFrom(start)
 	currentToken := p.current()
-	for bindingPower < bindingPowers[currentToken] {
+	for bindingPower < ledBindingPower(currentToken) {
 		p.advance()
 		leftNode, err = p.led(currentToken, leftNode)
 		if err != nil {
 			return ASTNode{}, err
 		}
+		leftNode.Span = p.spanFrom(start)
 		currentToken = p.current()
 	}
 	return leftNode, nil
 }
 
+// spanFrom returns the span from start to the end of the last token
+// consumed by the parser.
+func (p *Parser) spanFrom(start int) Span {
+	end := tokenStart(p.lookaheadToken(0))
+	if end < start {
+		end = start
+	}
+	text := strings.TrimRight(p.expression[start:end], " \t\n\r")
+	return Span{Start: start, End: start + len(text)}
+}
+
+// tokenStart returns the offset of the first character of t. The position
+// of a literal points past its opening delimiter.
+func tokenStart(t token) int {
+	if t.tokenType == tJSONLiteral || t.tokenType == tStringLiteral {
+		return t.position - 1
+	}
+	return t.position
+}
+
 func (p *Parser) parseIndexExpression() (ASTNode, error) {
 	if p.lookahead(0) == tColon || p.lookahead(1) == tColon {
 		return p.parseSliceExpression()
 	}
+	start := tokenStart(p.lookaheadToken(-1))
 	indexStr := p.lookaheadToken(0).value
 	parsedInt, err := strconv.Atoi(indexStr)
 	if err != nil {
 		return ASTNode{}, err
 	}
-	indexNode := ASTNode{nodeType: ASTIndex, value: parsedInt}
 	p.advance()
 	if err := p.match(tRbracket); err != nil {
 		return ASTNode{}, err
 	}
-	return indexNode, nil
+	return ASTNode{NodeType: ASTIndex, Value: parsedInt, Span: p.spanFrom(start)}, nil
 }
 
 func (p *Parser) parseSliceExpression() (ASTNode, error) {
+	start := tokenStart(p.lookaheadToken(-1))
 	parts := []*int{nil, nil, nil}
 	index := 0
 	current := p.current()
@@ -204,8 +323,9 @@ func (p *Parser) parseSliceExpression() (ASTNode, error) {
 		return ASTNode{}, err
 	}
 	return ASTNode{
-		nodeType: ASTSlice,
-		value:    parts,
+		NodeType: ASTSlice,
+		Value:    parts,
+		Span:     p.spanFrom(start),
 	}, nil
 }
 
@@ -223,27 +343,42 @@ func (p *Parser) led(tokenType tokType, node ASTNode) (ASTNode, error) {
 		if p.current() != tStar {
 			right, err := p.parseDotRHS(bindingPowers[tDot])
 			return ASTNode{
-				nodeType: ASTSubexpression,
-				children: []ASTNode{node, right},
+				NodeType: ASTSubexpression,
+				Children: []ASTNode{node, right},
 			}, err
 		}
 		p.advance()
 		right, err := p.parseProjectionRHS(bindingPowers[tDot])
 		return ASTNode{
-			nodeType: ASTValueProjection,
-			children: []ASTNode{node, right},
+			NodeType: ASTValueProjection,
+			Children: []ASTNode{node, right},
 		}, err
 	case tPipe:
 		right, err := p.parseExpression(bindingPowers[tPipe])
-		return ASTNode{nodeType: ASTPipe, children: []ASTNode{node, right}}, err
+		return ASTNode{NodeType: ASTPipe, Children: []ASTNode{node, right}}, err
 	case tOr:
 		right, err := p.parseExpression(bindingPowers[tOr])
-		return ASTNode{nodeType: ASTOrExpression, children: []ASTNode{node, right}}, err
+		return ASTNode{NodeType: ASTOrExpression, Children: []ASTNode{node, right}}, err
 	case tAnd:
 		right, err := p.parseExpression(bindingPowers[tAnd])
-		return ASTNode{nodeType: ASTAndExpression, children: []ASTNode{node, right}}, err
+		return ASTNode{NodeType: ASTAndExpression, Children: []ASTNode{node, right}}, err
+	case tQuestion:
+		then, err := p.parseExpression(0)
+		if err != nil {
+			return ASTNode{}, err
+		}
+		if err := p.match(tColon); err != nil {
+			return ASTNode{}, err
+		}
+		// Parsing the else branch just below the binding power of "?"
+		// makes "a ? b : c ? d : e" group as "a ? b : (c ? d : e)".
+		otherwise, err := p.parseExpression(bindingPowers[tQuestion] - 1)
+		return ASTNode{
+			NodeType: ASTTernaryExpression,
+			Children: []ASTNode{node, then, otherwise},
+		}, err
 	case tLparen:
-		name := node.value
+		name := node.Value
 		var args []ASTNode
 		for p.current() != tRparen {
 			expression, err := p.parseExpression(0)
@@ -261,18 +396,18 @@ func (p *Parser) led(tokenType tokType, node ASTNode) (ASTNode, error) {
 			return ASTNode{}, err
 		}
 		return ASTNode{
-			nodeType: ASTFunctionExpression,
-			value:    name,
-			children: args,
+			NodeType: ASTFunctionExpression,
+			Value:    name,
+			Children: args,
 		}, nil
 	case tFilter:
 		return p.parseFilter(node)
 	case tFlatten:
-		left := ASTNode{nodeType: ASTFlatten, children: []ASTNode{node}}
+		left := ASTNode{NodeType: ASTFlatten, Children: []ASTNode{node}}
 		right, err := p.parseProjectionRHS(bindingPowers[tFlatten])
 		return ASTNode{
-			nodeType: ASTProjection,
-			children: []ASTNode{left, right},
+			NodeType: ASTProjection,
+			Children: []ASTNode{left, right},
 		}, err
 	case tEQ, tNE, tGT, tGTE, tLT, tLTE:
 		right, err := p.parseExpression(bindingPowers[tokenType])
@@ -280,9 +415,19 @@ func (p *Parser) led(tokenType tokType, node ASTNode) (ASTNode, error) {
 			return ASTNode{}, err
 		}
 		return ASTNode{
-			nodeType: ASTComparator,
-			value:    tokenType,
-			children: []ASTNode{node, right},
+			NodeType: ASTComparator,
+			Value:    tokenType,
+			Children: []ASTNode{node, right},
+		}, nil
+	case tPlus, tMinus, tStar, tDivide, tModulo, tIntegerDivide:
+		right, err := p.parseExpression(ledBindingPower(tokenType))
+		if err != nil {
+			return ASTNode{}, err
+		}
+		return ASTNode{
+			NodeType: ASTArithmeticExpression,
+			Value:    tokenType,
+			Children: []ASTNode{node, right},
 		}, nil
 	case tLbracket:
 		tokenType := p.current()
@@ -307,8 +452,8 @@ func (p *Parser) led(tokenType tokType, node ASTNode) (ASTNode, error) {
 			return ASTNode{}, err
 		}
 		return ASTNode{
-			nodeType: ASTProjection,
-			children: []ASTNode{node, right},
+			NodeType: ASTProjection,
+			Children: []ASTNode{node, right},
 		}, nil
 	}
 	return ASTNode{}, p.syntaxError("Unexpected token: " + tokenType.String())
@@ -322,44 +467,49 @@ func (p *Parser) nud(token token) (ASTNode, error) {
 		if err != nil {
 			return ASTNode{}, err
 		}
-		return ASTNode{nodeType: ASTLiteral, value: parsed}, nil
+		return ASTNode{NodeType: ASTLiteral, Value: parsed}, nil
 	case tStringLiteral:
-		return ASTNode{nodeType: ASTLiteral, value: token.value}, nil
+		return ASTNode{NodeType: ASTLiteral, Value: token.value}, nil
 	case tUnquotedIdentifier:
+		if token.value == "let" && p.current() == tVariable {
+			return p.parseLetExpression()
+		}
 		return ASTNode{
-			nodeType: ASTField,
-			value:    token.value,
+			NodeType: ASTField,
+			Value:    token.value,
 		}, nil
+	case tVariable:
+		return ASTNode{NodeType: ASTVariable, Value: token.value}, nil
 	case tQuotedIdentifier:
-		node := ASTNode{nodeType: ASTField, value: token.value}
+		node := ASTNode{NodeType: ASTField, Value: token.value}
 		if p.current() == tLparen {
 			return ASTNode{}, p.syntaxErrorToken("Can't have quoted identifier as function name.", token)
 		}
 		return node, nil
 	case tStar:
-		left := ASTNode{nodeType: ASTIdentity}
+		left := ASTNode{NodeType: ASTIdentity}
 		var right ASTNode
 		var err error
 		if p.current() == tRbracket {
-			right = ASTNode{nodeType: ASTIdentity}
+			right = ASTNode{NodeType: ASTIdentity}
 		} else {
 			right, err = p.parseProjectionRHS(bindingPowers[tStar])
 		}
-		return ASTNode{nodeType: ASTValueProjection, children: []ASTNode{left, right}}, err
+		return ASTNode{NodeType: ASTValueProjection, Children: []ASTNode{left, right}}, err
 	case tFilter:
-		return p.parseFilter(ASTNode{nodeType: ASTIdentity})
+		return p.parseFilter(ASTNode{NodeType: ASTIdentity})
 	case tLbrace:
 		return p.parseMultiSelectHash()
 	case tFlatten:
 		left := ASTNode{
-			nodeType: ASTFlatten,
-			children: []ASTNode{{nodeType: ASTIdentity}},
+			NodeType: ASTFlatten,
+			Children: []ASTNode{{NodeType: ASTIdentity}},
 		}
 		right, err := p.parseProjectionRHS(bindingPowers[tFlatten])
 		if err != nil {
 			return ASTNode{}, err
 		}
-		return ASTNode{nodeType: ASTProjection, children: []ASTNode{left, right}}, nil
+		return ASTNode{NodeType: ASTProjection, Children: []ASTNode{left, right}}, nil
 	case tLbracket:
 		tokenType := p.current()
 		//var right ASTNode
@@ -368,7 +518,7 @@ func (p *Parser) nud(token token) (ASTNode, error) {
 			if err != nil {
 				return ASTNode{}, nil
 			}
-			return p.projectIfSlice(ASTNode{nodeType: ASTIdentity}, right)
+			return p.projectIfSlice(ASTNode{NodeType: ASTIdentity}, right)
 		} else if tokenType == tStar && p.lookahead(1) == tRbracket {
 			p.advance()
 			p.advance()
@@ -377,26 +527,38 @@ func (p *Parser) nud(token token) (ASTNode, error) {
 				return ASTNode{}, err
 			}
 			return ASTNode{
-				nodeType: ASTProjection,
-				children: []ASTNode{{nodeType: ASTIdentity}, right},
+				NodeType: ASTProjection,
+				Children: []ASTNode{{NodeType: ASTIdentity}, right},
 			}, nil
 		} else {
 			return p.parseMultiSelectList()
 		}
 	case tCurrent:
-		return ASTNode{nodeType: ASTCurrentNode}, nil
+		return ASTNode{NodeType: ASTCurrentNode}, nil
+	case tRoot:
+		return ASTNode{NodeType: ASTRootNode}, nil
 	case tExpref:
 		expression, err := p.parseExpression(bindingPowers[tExpref])
 		if err != nil {
 			return ASTNode{}, err
 		}
-		return ASTNode{nodeType: ASTExpRef, children: []ASTNode{expression}}, nil
+		return ASTNode{NodeType: ASTExpRef, Children: []ASTNode{expression}}, nil
 	case tNot:
 		expression, err := p.parseExpression(bindingPowers[tNot])
 		if err != nil {
 			return ASTNode{}, err
 		}
-		return ASTNode{nodeType: ASTNotExpression, children: []ASTNode{expression}}, nil
+		return ASTNode{NodeType: ASTNotExpression, Children: []ASTNode{expression}}, nil
+	case tPlus, tMinus:
+		expression, err := p.parseExpression(unaryBindingPower)
+		if err != nil {
+			return ASTNode{}, err
+		}
+		return ASTNode{
+			NodeType: ASTArithmeticUnaryExpression,
+			Value:    token.tokenType,
+			Children: []ASTNode{expression},
+		}, nil
 	case tLparen:
 		expression, err := p.parseExpression(0)
 		if err != nil {
@@ -434,8 +596,8 @@ func (p *Parser) parseMultiSelectList() (ASTNode, error) {
 		return ASTNode{}, err
 	}
 	return ASTNode{
-		nodeType: ASTMultiSelectList,
-		children: expressions,
+		NodeType: ASTMultiSelectList,
+		Children: expressions,
 	}, nil
 }
 
@@ -458,9 +620,10 @@ func (p *Parser) parseMultiSelectHash() (ASTNode, error) {
 			return ASTNode{}, err
 		}
 		node := ASTNode{
-			nodeType: ASTKeyValPair,
-			value:    keyName,
-			children: []ASTNode{value},
+			NodeType: ASTKeyValPair,
+			Value:    keyName,
+			Children: []ASTNode{value},
+			Span:     p.spanFrom(tokenStart(keyToken)),
 		}
 		children = append(children, node)
 		if p.current() == tComma {
@@ -477,21 +640,64 @@ func (p *Parser) parseMultiSelectHash() (ASTNode, error) {
 		}
 	}
 	return ASTNode{
-		nodeType: ASTMultiSelectHash,
-		children: children,
+		NodeType: ASTMultiSelectHash,
+		Children: children,
+	}, nil
+}
+
+// parseLetExpression parses "let $a = expr, $b = expr in body". The
+// resulting node has one ASTVariableBinding child per variable followed
+// by the body.
+func (p *Parser) parseLetExpression() (ASTNode, error) {
+	var children []ASTNode
+	for {
+		variable := p.lookaheadToken(0)
+		if err := p.match(tVariable); err != nil {
+			return ASTNode{}, err
+		}
+		if err := p.match(tAssign); err != nil {
+			return ASTNode{}, err
+		}
+		value, err := p.parseExpression(0)
+		if err != nil {
+			return ASTNode{}, err
+		}
+		children = append(children, ASTNode{
+			NodeType: ASTVariableBinding,
+			Value:    variable.value,
+			Children: []ASTNode{value},
+			Span:     p.spanFrom(tokenStart(variable)),
+		})
+		if p.current() != tComma {
+			break
+		}
+		p.advance()
+	}
+	in := p.lookaheadToken(0)
+	if in.tokenType != tUnquotedIdentifier || in.value != "in" {
+		return ASTNode{}, p.syntaxError("Expected 'in', received: " + p.current().String())
+	}
+	p.advance()
+	body, err := p.parseExpression(0)
+	if err != nil {
+		return ASTNode{}, err
+	}
+	return ASTNode{
+		NodeType: ASTLetExpression,
+		Children: append(children, body),
 	}, nil
 }
 
 func (p *Parser) projectIfSlice(left ASTNode, right ASTNode) (ASTNode, error) {
 	indexExpr := ASTNode{
-		nodeType: ASTIndexExpression,
-		children: []ASTNode{left, right},
+		NodeType: ASTIndexExpression,
+		Children: []ASTNode{left, right},
 	}
-	if right.nodeType == ASTSlice {
+	if right.NodeType == ASTSlice {
 		right, err := p.parseProjectionRHS(bindingPowers[tStar])
 		return ASTNode{
-			nodeType: ASTProjection,
-			children: []ASTNode{indexExpr, right},
+			NodeType: ASTProjection,
+			Children: []ASTNode{indexExpr, right},
 		}, err
 	}
 	return indexExpr, nil
@@ -507,7 +713,7 @@ func (p *Parser) parseFilter(node ASTNode) (ASTNode, error) {
 		return ASTNode{}, err
 	}
 	if p.current() == tFlatten {
-		right = ASTNode{nodeType: ASTIdentity}
+		right = ASTNode{NodeType: ASTIdentity}
 	} else {
 		right, err = p.parseProjectionRHS(bindingPowers[tFilter])
 		if err != nil {
@@ -516,8 +722,8 @@ func (p *Parser) parseFilter(node ASTNode) (ASTNode, error) {
 	}
 
 	return ASTNode{
-		nodeType: ASTFilterProjection,
-		children: []ASTNode{node, right, condition},
+		NodeType: ASTFilterProjection,
+		Children: []ASTNode{node, right, condition},
 	}, nil
 }
 
@@ -525,24 +731,34 @@ func (p *Parser) parseDotRHS(bindingPower int) (ASTNode, error) {
 	lookahead := p.current()
 	if tokensOneOf([]tokType{tQuotedIdentifier, tUnquotedIdentifier, tStar}, lookahead) {
 		return p.parseExpression(bindingPower)
-	} else if lookahead == tLbracket {
+	}
+	start := tokenStart(p.lookaheadToken(0))
+	var node ASTNode
+	var err error
+	if lookahead == tLbracket {
 		if err := p.match(tLbracket); err != nil {
 			return ASTNode{}, err
 		}
-		return p.parseMultiSelectList()
+		node, err = p.parseMultiSelectList()
 	} else if lookahead == tLbrace {
 		if err := p.match(tLbrace); err != nil {
 			return ASTNode{}, err
 		}
-		return p.parseMultiSelectHash()
+		node, err = p.parseMultiSelectHash()
+	} else {
+		return ASTNode{}, p.syntaxError("Expected identifier, lbracket, or lbrace")
+	}
+	if err != nil {
+		return ASTNode{}, err
 	}
-	return ASTNode{}, p.syntaxError("Expected identifier, lbracket, or lbrace")
+	node.Span = p.spanFrom(start)
+	return node, nil
 }
 
 func (p *Parser) parseProjectionRHS(bindingPower int) (ASTNode, error) {
 	current := p.current()
-	if bindingPowers[current] < 10 {
-		return ASTNode{nodeType: ASTIdentity}, nil
+	if ledBindingPower(current) < 10 {
+		return ASTNode{NodeType: ASTIdentity}, nil
 	} else if current == tLbracket {
 		return p.parseExpression(bindingPower)
 	} else if current == tFilter {
@@ -24,6 +24,19 @@ var parsingErrorTests = []struct {
 	{`foo@`, "Invalid"},
 	{`&&&&&&&&&&&&t(`, "Invalid"},
 	{`[*][`, "Invalid"},
+	{`let $x = foo`, "Missing in"},
+	{`let $x in foo`, "Missing binding"},
+	{`let $x = foo, in @`, "Missing binding"},
+	{`let $x = foo in`, "Incomplete expression"},
+	{`let x = foo in @`, "Invalid"},
+	{`a +`, "Incomplete expression"},
+	{`* a`, "Invalid"},
+	{`a - 1`, "Numbers require a literal"},
+	{`$1`, "Invalid"},
+	{`[?foo==bar?]`, "Incomplete expression"},
+	{`a ? b`, "Missing colon"},
+	{`a ? b :`, "Incomplete expression"},
+	{`? b : c`, "Invalid"},
 }
 
 func TestParsingErrors(t *testing.T) {
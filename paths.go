@@ -0,0 +1,356 @@
+package jmespath
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Match is a value matched by SearchPaths, along with its location in the
+// searched data.
+type Match struct {
+	Value interface{} `json:"value"`
+	// Path is the location of the value in the data, as an RFC 6901 JSON
+	// Pointer such as "/people/0/name". It is nil for values computed by
+	// the expression, such as the results of functions.
+	Path *string `json:"path"`
+}
+
+// SearchPaths evaluates the expression against data like Search, and
+// returns the values it matched along with their locations in data. The
+// elements of the arrays built by projections, flattens, slices and
+// multi-select lists are matched separately; any other result is a single
+// match. Null values are not matches, so a null result has no matches.
+//
+// Locations are kept track of through fields, indexes, slices,
+// projections, flattens and the operators selecting one of their
+// operands, such as ||. The values of other expressions, and of variables,
+// are computed.
+func (jp *JMESPath) SearchPaths(data interface{}) ([]Match, error) {
+	intr := jp.newEvaluation()
+	intr.root = data
+	result, err := intr.locate(jp.ast, located{value: data, path: []string{}})
+	if err != nil {
+		return nil, err
+	}
+	if err := intr.checkResult(result.value); err != nil {
+		return nil, err
+	}
+	values := []located{result}
+	if result.elements != nil {
+		values = result.elements
+	}
+	matches := []Match{}
+	for _, value := range values {
+		if value.value == nil {
+			continue
+		}
+		match := Match{Value: value.value}
+		if value.path != nil {
+			pointer := jsonPointer(value.path)
+			match.Path = &pointer
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+// SearchPaths evaluates a JMESPath expression against input data and
+// returns the values it matched along with their locations, as described
+// for JMESPath.SearchPaths.
+func SearchPaths(expression string, data interface{}) ([]Match, error) {
+	jp, err := defaultCache.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return jp.SearchPaths(data)
+}
+
+// located is a value produced while evaluating an expression, along with
+// its location in the data.
+type located struct {
+	value interface{}
+	// path holds the reference tokens of the location of value, it is nil
+	// if value was computed.
+	path []string
+	// elements are set when value is an array built by the expression,
+	// from elements that have their own locations.
+	elements []located
+}
+
+// child returns the location of a member or element of the value.
+func (l located) child(token string) []string {
+	if l.path == nil {
+		return nil
+	}
+	return append(l.path[:len(l.path):len(l.path)], token)
+}
+
+// items returns the elements of the value with their locations, or nil if
+// the value isn't an array.
+func (l located) items() []located {
+	if l.elements != nil {
+		return l.elements
+	}
+	values := projectionItems(l.value)
+	if values == nil {
+		return nil
+	}
+	items := make([]located, len(values))
+	for i, value := range values {
+		items[i] = located{value: value, path: l.child(strconv.Itoa(i))}
+	}
+	return items
+}
+
+// collect returns the array built from elements.
+func collect(elements []located) located {
+	values := make([]interface{}, len(elements))
+	for i, element := range elements {
+		values[i] = element.value
+	}
+	if elements == nil {
+		elements = []located{}
+	}
+	return located{value: values, elements: elements}
+}
+
+// locate is like Execute, but keeps track of the location of the values
+// it produces.
+func (intr *treeInterpreter) locate(node ASTNode, value located) (located, error) {
+	if intr.limits == (Limits{}) {
+		return intr.locateNode(node, value)
+	}
+	if err := intr.enter(); err != nil {
+		return located{}, err
+	}
+	result, err := intr.locateNode(node, value)
+	intr.depth--
+	if err == nil {
+		err = intr.checkCollection(node.NodeType, result.value)
+	}
+	if err != nil {
+		return located{}, err
+	}
+	return result, nil
+}
+
+func (intr *treeInterpreter) locateNode(node ASTNode, value located) (located, error) {
+	switch node.NodeType {
+	case ASTField:
+		key := node.Value.(string)
+		result, err := intr.field(key, value.value)
+		if err != nil || result == nil {
+			return located{}, err
+		}
+		return located{value: result, path: value.child(memberName(key, value.value))}, nil
+	case ASTIndex:
+		items := value.items()
+		i := node.Value.(int)
+		if i < 0 {
+			i += len(items)
+		}
+		if i < 0 || i >= len(items) {
+			return located{}, nil
+		}
+		return items[i], nil
+	case ASTSlice:
+		items := value.items()
+		if items == nil {
+			return located{}, nil
+		}
+		indexes := make([]interface{}, len(items))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		selected, err := slice(indexes, sliceParams(node.Value.([]*int)))
+		if err != nil {
+			return located{}, err
+		}
+		elements := make([]located, len(selected))
+		for i, index := range selected {
+			elements[i] = items[index.(int)]
+		}
+		return collect(elements), nil
+	case ASTIdentity, ASTCurrentNode:
+		return value, nil
+	case ASTRootNode:
+		return located{value: intr.root, path: []string{}}, nil
+	case ASTSubexpression, ASTIndexExpression:
+		left, err := intr.locate(node.Children[0], value)
+		if err != nil {
+			return located{}, err
+		}
+		return intr.locate(node.Children[1], left)
+	case ASTPipe:
+		result := value
+		var err error
+		for _, child := range node.Children {
+			result, err = intr.locate(child, result)
+			if err != nil {
+				return located{}, err
+			}
+		}
+		return result, nil
+	case ASTProjection, ASTFilterProjection, ASTFirstMatch:
+		left, err := intr.locate(node.Children[0], value)
+		if err != nil {
+			return located{}, err
+		}
+		items := left.items()
+		if items == nil {
+			return located{}, nil
+		}
+		return intr.locateProjection(node, items)
+	case ASTValueProjection:
+		left, err := intr.locate(node.Children[0], value)
+		if err != nil {
+			return located{}, err
+		}
+		object, ok := toObject(left.value)
+		if !ok {
+			return located{}, nil
+		}
+		items := make([]located, 0, len(object))
+		for key, member := range object {
+			items = append(items, located{value: member, path: left.child(key)})
+		}
+		return intr.locateProjection(node, items)
+	case ASTFlatten:
+		left, err := intr.locate(node.Children[0], value)
+		if err != nil {
+			return located{}, err
+		}
+		items := left.items()
+		if items == nil {
+			return located{}, nil
+		}
+		flattened := []located{}
+		for _, item := range items {
+			if err := intr.checkContext(); err != nil {
+				return located{}, err
+			}
+			if isSliceType(item.value) {
+				flattened = append(flattened, item.items()...)
+			} else {
+				flattened = append(flattened, item)
+			}
+			if err := intr.checkCollectionSize(len(flattened)); err != nil {
+				return located{}, err
+			}
+		}
+		return collect(flattened), nil
+	case ASTMultiSelectList:
+		if value.value == nil {
+			return located{}, nil
+		}
+		elements := make([]located, 0, len(node.Children))
+		for _, child := range node.Children {
+			current, err := intr.locate(child, value)
+			if err != nil {
+				return located{}, err
+			}
+			elements = append(elements, current)
+		}
+		return collect(elements), nil
+	case ASTOrExpression:
+		matched, err := intr.locate(node.Children[0], value)
+		if err != nil {
+			return located{}, err
+		}
+		if isFalse(matched.value) {
+			return intr.locate(node.Children[1], value)
+		}
+		return matched, nil
+	case ASTAndExpression:
+		matched, err := intr.locate(node.Children[0], value)
+		if err != nil {
+			return located{}, err
+		}
+		if isFalse(matched.value) {
+			return matched, nil
+		}
+		return intr.locate(node.Children[1], value)
+	case ASTTernaryExpression:
+		condition, err := intr.Execute(node.Children[0], value.value)
+		if err != nil {
+			return located{}, err
+		}
+		if isFalse(condition) {
+			return intr.locate(node.Children[2], value)
+		}
+		return intr.locate(node.Children[1], value)
+	}
+	result, err := intr.execute(node, value.value)
+	if err != nil {
+		return located{}, err
+	}
+	return located{value: result}, nil
+}
+
+// locateProjection applies the right hand side of a projection to its
+// items, keeping those matching the condition of filter projections.
+func (intr *treeInterpreter) locateProjection(node ASTNode, items []located) (located, error) {
+	collected := []located{}
+	for _, item := range items {
+		if err := intr.checkContext(); err != nil {
+			return located{}, err
+		}
+		if node.NodeType == ASTFilterProjection || node.NodeType == ASTFirstMatch {
+			result, err := intr.Execute(node.Children[2], item.value)
+			if err != nil {
+				return located{}, err
+			}
+			if isFalse(result) {
+				continue
+			}
+		}
+		current, err := intr.locate(node.Children[1], item)
+		if err != nil {
+			return located{}, err
+		}
+		if current.value == nil {
+			continue
+		}
+		if node.NodeType == ASTFirstMatch {
+			return current, nil
+		}
+		collected = append(collected, current)
+		if err := intr.checkCollectionSize(len(collected)); err != nil {
+			return located{}, err
+		}
+	}
+	if node.NodeType == ASTFirstMatch {
+		return located{}, nil
+	}
+	return collect(collected), nil
+}
+
+// memberName returns the name under which the value of the key is found
+// in value. It is the key itself, except for struct fields matched
+// regardless of case.
+func memberName(key string, value interface{}) string {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Struct {
+		if field := cachedStructFields(rv.Type()).lookup(key); field != nil {
+			return field.name
+		}
+	}
+	return key
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// jsonPointer returns the RFC 6901 JSON Pointer made of the reference
+// tokens.
+func jsonPointer(tokens []string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteByte('/')
+		pointerEscaper.WriteString(&b, token)
+	}
+	return b.String()
+}
@@ -0,0 +1,203 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+var searchPathsTests = []struct {
+	expression string
+	paths      []interface{}
+}{
+	{"people[0].name", []interface{}{"/people/0/name"}},
+	{"people[-1]", []interface{}{"/people/2"}},
+	{"people", []interface{}{"/people"}},
+	{"people[*].name", []interface{}{"/people/0/name", "/people/1/name", "/people/2/name"}},
+	{"people[?age > `20`].name", []interface{}{"/people/1/name", "/people/2/name"}},
+	{"people[?age > `20`] | [0].name", []interface{}{"/people/1/name"}},
+	{"people[::-2].age", []interface{}{"/people/2/age", "/people/0/age"}},
+	{"people[].tags[]", []interface{}{"/people/0/tags/0", "/people/0/tags/1", "/people/1/tags/0"}},
+	{"people[*].tags[0]", []interface{}{"/people/0/tags/0", "/people/1/tags/0"}},
+	{"people[1:].tags | [1]", []interface{}{"/people/2/tags"}},
+	{"config.\"a/b\".\"~c\"", []interface{}{"/config/a~1b/~0c"}},
+	{"config.*.\"~c\"", []interface{}{"/config/a~1b/~0c"}},
+	{"config.empty || config.enabled", []interface{}{"/config/enabled"}},
+	{"config.enabled && people[0].age", []interface{}{"/people/0/age"}},
+	{"config.enabled ? people[1].name : people[0].name", []interface{}{"/people/1/name"}},
+	{"[people[0].name, config.missing, length(people)]", []interface{}{"/people/0/name", nil}},
+	{"people[0].{n: name}", []interface{}{nil}},
+	{"sort_by(people, &age)[0].name", []interface{}{nil}},
+	{"people[0].name | $.config.enabled", []interface{}{"/config/enabled"}},
+	{"@", []interface{}{""}},
+	{"missing", []interface{}{}},
+	{"people[?age > `99`]", []interface{}{}},
+}
+
+func TestSearchPaths(t *testing.T) {
+	assert := assert.New(t)
+	for _, tt := range searchPathsTests {
+		data := decodeJSON(t, `{
+			"people": [
+				{"name": "a", "age": 17, "tags": ["x", "y"]},
+				{"name": "b", "age": 42, "tags": ["z"]},
+				{"name": "c", "age": 23, "tags": []}
+			],
+			"config": {"a/b": {"~c": 1}, "enabled": true, "empty": ""}
+		}`)
+		matches, err := SearchPaths(tt.expression, data)
+		if !assert.Nil(err, tt.expression) {
+			continue
+		}
+		paths := []interface{}{}
+		for _, match := range matches {
+			if match.Path == nil {
+				paths = append(paths, nil)
+				continue
+			}
+			paths = append(paths, *match.Path)
+			value, ok := resolvePointer(data, *match.Path)
+			assert.True(ok, "%s: %s", tt.expression, *match.Path)
+			assert.Equal(value, match.Value, "%s: %s", tt.expression, *match.Path)
+		}
+		assert.Equal(tt.paths, paths, tt.expression)
+	}
+}
+
+func TestSearchPathsOfStructs(t *testing.T) {
+	assert := assert.New(t)
+	data := map[string]interface{}{"users": []taggedStruct{{UserID: "u1"}}}
+	matches, err := SearchPaths("users[0].USER_ID", data)
+	assert.Nil(err)
+	if assert.Len(matches, 1) {
+		assert.Equal("u1", matches[0].Value)
+		assert.Equal("/users/0/user_id", *matches[0].Path)
+	}
+	encoded, err := json.Marshal(Match{Value: 1.0})
+	assert.Nil(err)
+	assert.Equal(`{"value":1,"path":null}`, string(encoded))
+}
+
+func TestSearchPathsErrors(t *testing.T) {
+	assert := assert.New(t)
+	data := decodeJSON(t, `{"people": [{"tags": ["x", "y"]}, {"tags": ["z"]}]}`)
+	for _, expression := range []string{"people[::0]", "abs(people)"} {
+		_, err := SearchPaths(expression, data)
+		assert.NotNil(err, expression)
+	}
+	_, err := MustCompile("people[*].tags[*]").WithLimits(Limits{MaxSteps: 5}).SearchPaths(data)
+	assert.NotNil(err)
+}
+
+// TestSearchPathsCompliance checks that SearchPaths finds the values of
+// Search, at the locations they come from.
+func TestSearchPathsCompliance(t *testing.T) {
+	assert := assert.New(t)
+	files, err := filepath.Glob("compliance/*.json")
+	assert.Nil(err)
+	for _, filename := range files {
+		data, err := ioutil.ReadFile(filename)
+		if !assert.Nil(err) {
+			continue
+		}
+		var suites []TestSuite
+		if !assert.Nil(json.Unmarshal(data, &suites), filename) {
+			continue
+		}
+		for _, suite := range suites {
+			document, err := json.Marshal(suite.Given)
+			if !assert.Nil(err, filename) {
+				continue
+			}
+			for _, testcase := range suite.TestCases {
+				compiled, err := Compile(testcase.Expression)
+				if err != nil {
+					continue
+				}
+				// sort_by sorts its input in place, each search gets
+				// its own copy of the document.
+				var given interface{}
+				assert.Nil(json.Unmarshal(document, &given))
+				expected, expectedErr := compiled.Search(given)
+				assert.Nil(json.Unmarshal(document, &given))
+				intr := compiled.newEvaluation()
+				intr.root = given
+				actual, actualErr := intr.locate(compiled.ast, located{value: given, path: []string{}})
+				assert.Equal(expectedErr, actualErr, "%s: %s", filename, testcase.Expression)
+				if !sameValues(expected, actual.value) {
+					assert.Equal(expected, actual.value, "%s: %s", filename, testcase.Expression)
+				}
+				if actualErr != nil {
+					continue
+				}
+				matches, err := compiled.SearchPaths(given)
+				assert.Nil(err)
+				for _, match := range matches {
+					if match.Path == nil {
+						continue
+					}
+					value, ok := resolvePointer(given, *match.Path)
+					assert.True(ok, "%s: %s: %s", filename, testcase.Expression, *match.Path)
+					assert.Equal(value, match.Value, "%s: %s: %s", filename, testcase.Expression, *match.Path)
+				}
+			}
+		}
+	}
+}
+
+// sameValues compares two results, ignoring the order of the values of
+// objects, which value projections and values() don't guarantee.
+func sameValues(expected, actual interface{}) bool {
+	e, eok := expected.([]interface{})
+	a, aok := actual.([]interface{})
+	if !eok || !aok || len(e) != len(a) {
+		return objsEqual(expected, actual)
+	}
+	encode := func(values []interface{}) []string {
+		encoded := make([]string, len(values))
+		for i, value := range values {
+			b, _ := json.Marshal(value)
+			encoded[i] = string(b)
+		}
+		sort.Strings(encoded)
+		return encoded
+	}
+	return strings.Join(encode(e), ",") == strings.Join(encode(a), ",")
+}
+
+func resolvePointer(data interface{}, pointer string) (interface{}, bool) {
+	if pointer == "" {
+		return data, true
+	}
+	unescaper := strings.NewReplacer("~1", "/", "~0", "~")
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = unescaper.Replace(token)
+		switch v := data.(type) {
+		case map[string]interface{}:
+			value, ok := v[token]
+			if !ok {
+				return nil, false
+			}
+			data = value
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			data = v[i]
+		default:
+			value, err := Search(quoteIdentifier(token), data)
+			if err != nil || value == nil {
+				return nil, false
+			}
+			data = value
+		}
+	}
+	return data, true
+}
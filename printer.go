@@ -0,0 +1,303 @@
+package jmespath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// atomBindingPower is the binding power of nodes that are complete on
+// their own, it is higher than the binding power of any token.
+const atomBindingPower = 100
+
+var unquotedIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Expression returns the node as a JMESPath expression. Parsing the
+// expression returns an equivalent AST. Identifiers are only quoted when
+// they need to be, string literals are written as raw string literals
+// where possible, and parentheses are only added where the structure of
+// the AST requires them.
+func (node ASTNode) Expression() string {
+	text, _ := unparse(node)
+	return text
+}
+
+// unparse returns the expression text of node, along with the binding
+// power up to which a token written after the text would still end the
+// expression. A token with a higher binding power would be parsed as
+// part of the rightmost operand of node.
+func unparse(node ASTNode) (string, int) {
+	switch node.NodeType {
+	case ASTField:
+		return quoteIdentifier(node.Value.(string)), atomBindingPower
+	case ASTLiteral:
+		return quoteLiteral(node.Value), atomBindingPower
+	case ASTCurrentNode, ASTIdentity:
+		return "@", atomBindingPower
+	case ASTRootNode:
+		return "$", atomBindingPower
+	case ASTVariable:
+		return "$" + node.Value.(string), atomBindingPower
+	case ASTIndex:
+		return "[" + strconv.Itoa(node.Value.(int)) + "]", atomBindingPower
+	case ASTSlice:
+		return unparseSlice(node.Value.([]*int)), atomBindingPower
+	case ASTSubexpression:
+		// The right hand side of a dot can't be parenthesized.
+		rhs, open := unparse(node.Children[1])
+		return unparseLeft(node.Children[0], bindingPowers[tDot]) + "." + rhs, minInt(bindingPowers[tDot], open)
+	case ASTIndexExpression:
+		index, _ := unparse(node.Children[1])
+		if node.Children[0].NodeType == ASTIdentity {
+			return index, atomBindingPower
+		}
+		return unparseLeft(node.Children[0], bindingPowers[tLbracket]) + index, atomBindingPower
+	case ASTProjection:
+		return unparseProjection(node)
+	case ASTValueProjection:
+		text := "*"
+		if node.Children[0].NodeType != ASTIdentity {
+			text = unparseLeft(node.Children[0], bindingPowers[tDot]) + ".*"
+		}
+		return unparseProjectionRHS(text, node.Children[1], bindingPowers[tStar])
+	case ASTFilterProjection:
+		condition, _ := unparse(node.Children[2])
+		text := "[?" + condition + "]"
+		if node.Children[0].NodeType != ASTIdentity {
+			text = unparseLeft(node.Children[0], bindingPowers[tFilter]) + text
+		}
+		return unparseProjectionRHS(text, node.Children[1], bindingPowers[tFilter])
+	case ASTFirstMatch:
+		// Written as the filter projection piped into [0] that it
+		// replaces.
+		filter := node
+		filter.NodeType = ASTFilterProjection
+		first := ASTNode{
+			NodeType: ASTIndexExpression,
+			Children: []ASTNode{{NodeType: ASTIdentity}, {NodeType: ASTIndex, Value: 0}},
+		}
+		pipe := ASTNode{NodeType: ASTPipe, Children: []ASTNode{filter, first}}
+		return unparseBinary(pipe, " | ", bindingPowers[tPipe])
+	case ASTFlatten:
+		return unparseLeft(node.Children[0], bindingPowers[tFlatten]) + "[]", bindingPowers[tFlatten]
+	case ASTMultiSelectList:
+		items := make([]string, len(node.Children))
+		for i, child := range node.Children {
+			items[i], _ = unparse(child)
+		}
+		return "[" + strings.Join(items, ", ") + "]", atomBindingPower
+	case ASTMultiSelectHash:
+		items := make([]string, len(node.Children))
+		for i, child := range node.Children {
+			items[i], _ = unparse(child)
+		}
+		return "{" + strings.Join(items, ", ") + "}", atomBindingPower
+	case ASTKeyValPair:
+		value, _ := unparse(node.Children[0])
+		return quoteIdentifier(node.Value.(string)) + ": " + value, atomBindingPower
+	case ASTFunctionExpression:
+		args := make([]string, len(node.Children))
+		for i, child := range node.Children {
+			args[i], _ = unparse(child)
+		}
+		return node.Value.(string) + "(" + strings.Join(args, ", ") + ")", atomBindingPower
+	case ASTExpRef:
+		return unparsePrefix("&", node.Children[0], bindingPowers[tExpref])
+	case ASTNotExpression:
+		return unparsePrefix("!", node.Children[0], bindingPowers[tNot])
+	case ASTArithmeticUnaryExpression:
+		return unparsePrefix(node.Operator(), node.Children[0], unaryBindingPower)
+	case ASTComparator, ASTArithmeticExpression:
+		return unparseBinary(node, " "+node.Operator()+" ", ledBindingPower(node.Value.(tokType)))
+	case ASTOrExpression:
+		return unparseBinary(node, " || ", bindingPowers[tOr])
+	case ASTAndExpression:
+		return unparseBinary(node, " && ", bindingPowers[tAnd])
+	case ASTPipe:
+		return unparseBinary(node, " | ", bindingPowers[tPipe])
+	case ASTTernaryExpression:
+		bindingPower := bindingPowers[tQuestion]
+		then, _ := unparse(node.Children[1])
+		otherwise, open := unparseRight(node.Children[2], bindingPower-1)
+		text := unparseLeft(node.Children[0], bindingPower) + " ? " + then + " : " + otherwise
+		return text, minInt(bindingPower-1, open)
+	case ASTLetExpression:
+		last := len(node.Children) - 1
+		bindings := make([]string, last)
+		for i, binding := range node.Children[:last] {
+			value, _ := unparse(binding.Children[0])
+			bindings[i] = "$" + binding.Value.(string) + " = " + value
+		}
+		body, _ := unparse(node.Children[last])
+		return "let " + strings.Join(bindings, ", ") + " in " + body, 0
+	}
+	return "", atomBindingPower
+}
+
+// precedence returns the binding power of the operator at the top of node,
+// or atomBindingPower if node doesn't start with an operand.
+func precedence(node ASTNode) int {
+	switch node.NodeType {
+	case ASTSubexpression:
+		return bindingPowers[tDot]
+	case ASTIndexExpression:
+		if node.Children[0].NodeType != ASTIdentity {
+			return bindingPowers[tLbracket]
+		}
+	case ASTProjection:
+		left := node.Children[0]
+		if left.NodeType == ASTFlatten {
+			left = left.Children[0]
+			if left.NodeType != ASTIdentity {
+				return bindingPowers[tFlatten]
+			}
+		} else if left.NodeType == ASTIndexExpression {
+			left = left.Children[0]
+		}
+		if left.NodeType != ASTIdentity {
+			return bindingPowers[tLbracket]
+		}
+	case ASTValueProjection:
+		if node.Children[0].NodeType != ASTIdentity {
+			return bindingPowers[tDot]
+		}
+	case ASTFilterProjection:
+		if node.Children[0].NodeType != ASTIdentity {
+			return bindingPowers[tFilter]
+		}
+	case ASTFlatten:
+		return bindingPowers[tFlatten]
+	case ASTComparator, ASTArithmeticExpression:
+		return ledBindingPower(node.Value.(tokType))
+	case ASTOrExpression:
+		return bindingPowers[tOr]
+	case ASTAndExpression:
+		return bindingPowers[tAnd]
+	case ASTPipe, ASTFirstMatch:
+		return bindingPowers[tPipe]
+	case ASTTernaryExpression:
+		return bindingPowers[tQuestion]
+	}
+	return atomBindingPower
+}
+
+// unparseLeft returns the text of node as the left operand of an operator
+// with the given binding power.
+func unparseLeft(node ASTNode, bindingPower int) string {
+	text, open := unparse(node)
+	if bindingPower > open {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// unparseRight returns the text of node as an operand that is parsed with
+// the given binding power.
+func unparseRight(node ASTNode, bindingPower int) (string, int) {
+	if precedence(node) <= bindingPower {
+		text, _ := unparse(node)
+		return "(" + text + ")", atomBindingPower
+	}
+	return unparse(node)
+}
+
+func unparseBinary(node ASTNode, operator string, bindingPower int) (string, int) {
+	right, open := unparseRight(node.Children[1], bindingPower)
+	return unparseLeft(node.Children[0], bindingPower) + operator + right, minInt(bindingPower, open)
+}
+
+func unparsePrefix(prefix string, operand ASTNode, bindingPower int) (string, int) {
+	text, open := unparseRight(operand, bindingPower)
+	return prefix + text, minInt(bindingPower, open)
+}
+
+func unparseProjection(node ASTNode) (string, int) {
+	left := node.Children[0]
+	var text string
+	bindingPower := bindingPowers[tStar]
+	switch {
+	case left.NodeType == ASTFlatten:
+		if left.Children[0].NodeType != ASTIdentity {
+			text = unparseLeft(left.Children[0], bindingPowers[tFlatten])
+		}
+		text += "[]"
+		bindingPower = bindingPowers[tFlatten]
+	case left.NodeType == ASTIndexExpression && left.Children[1].NodeType == ASTSlice:
+		text, _ = unparse(left)
+	default:
+		if left.NodeType != ASTIdentity {
+			text = unparseLeft(left, bindingPowers[tLbracket])
+		}
+		text += "[*]"
+	}
+	return unparseProjectionRHS(text, node.Children[1], bindingPower)
+}
+
+// unparseProjectionRHS appends the right hand side of a projection to the
+// text of its left hand side.
+func unparseProjectionRHS(text string, rhs ASTNode, bindingPower int) (string, int) {
+	if rhs.NodeType == ASTIdentity {
+		// parseProjectionRHS takes any token with a binding power of
+		// 10 or more as the start of the right hand side.
+		return text, minInt(bindingPower, 9)
+	}
+	right, open := unparse(rhs)
+	if !strings.HasPrefix(right, "[") {
+		right = "." + right
+	}
+	return text + right, minInt(bindingPower, open)
+}
+
+func unparseSlice(parts []*int) string {
+	text := make([]string, 0, 3)
+	for i, part := range parts {
+		if i == 2 && part == nil {
+			break
+		}
+		if part == nil {
+			text = append(text, "")
+		} else {
+			text = append(text, strconv.Itoa(*part))
+		}
+	}
+	return "[" + strings.Join(text, ":") + "]"
+}
+
+func quoteIdentifier(name string) string {
+	if unquotedIdentifier.MatchString(name) {
+		return name
+	}
+	return marshalJSON(name)
+}
+
+// quoteLiteral returns the text of a literal value. Strings are written as
+// raw string literals unless they contain a backslash, other values as JSON
+// literals.
+func quoteLiteral(value interface{}) string {
+	if s, ok := value.(string); ok && !strings.Contains(s, `\`) {
+		return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+	}
+	// Backticks can only occur in JSON strings, where they can be escaped
+	// so that they don't end the literal.
+	return "`" + strings.Replace(marshalJSON(value), "`", `\u0060`, -1) + "`"
+}
+
+func marshalJSON(value interface{}) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,151 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+// withoutSpans returns a copy of node with the spans cleared, so that
+// ASTs parsed from differently formatted expressions can be compared.
+func withoutSpans(node ASTNode) ASTNode {
+	node = node.copy()
+	Inspect(&node, func(n *ASTNode) bool {
+		if n != nil {
+			n.Span = Span{}
+		}
+		return true
+	})
+	return node
+}
+
+var printerTests = []struct {
+	expression string
+	expected   string
+}{
+	{"foo", "foo"},
+	{`"foo"`, "foo"},
+	{`"foo bar"`, `"foo bar"`},
+	{`"with \"quote\""`, `"with \"quote\""`},
+	{"`\"foo\"`", "'foo'"},
+	{`'it\'s'`, `'it\'s'`},
+	{"`\"back\\\\slash\"`", "`\"back\\\\slash\"`"},
+	{"`\"a\\`b\"`", "'a`b'"},
+	{"`{\"b\": 1, \"a\": [true, null]}`", "`{\"a\":[true,null],\"b\":1}`"},
+	{"foo.bar[0]", "foo.bar[0]"},
+	{"(foo.bar)[0]", "(foo.bar)[0]"},
+	{"foo[-1:]", "foo[-1:]"},
+	{"foo[::-1].bar", "foo[::-1].bar"},
+	{"foo[*].bar[*]", "foo[*].bar[*]"},
+	{"foo[*][0]", "foo[*][0]"},
+	{"(foo[*])[0]", "(foo[*])[0]"},
+	{"foo[].bar[]", "foo[].bar[]"},
+	{"[*]", "[*]"},
+	{"*.foo", "*.foo"},
+	{"foo.*.bar", "foo.*.bar"},
+	{"foo[?a == `1`].b", "foo[?a == `1`].b"},
+	{"[?a].b", "[?a].b"},
+	{"foo.[a, b]", "foo.[a, b]"},
+	{"foo[*].[a, b]", "foo[*][a, b]"},
+	{"foo.{a: a, \"b c\": b}", "foo.{a: a, \"b c\": b}"},
+	{"a || b && c", "a || b && c"},
+	{"(a || b) && c", "(a || b) && c"},
+	{"!(a || b)", "!(a || b)"},
+	{"!a.b", "!a.b"},
+	{"!(a.b)", "!(a.b)"},
+	{"(!a).b", "!a.b"},
+	{"a | b | c", "a | b | c"},
+	{"a | (b | c)", "a | (b | c)"},
+	{"a - b - c", "a - b - c"},
+	{"a - (b - c)", "a - (b - c)"},
+	{"(a + b) * c", "(a + b) * c"},
+	{"a * b + c", "a * b + c"},
+	{"-a.b", "-a.b"},
+	{"(-a).b", "(-a).b"},
+	{"a ? b : c ? d : e", "a ? b : c ? d : e"},
+	{"(a ? b : c) ? d : e", "(a ? b : c) ? d : e"},
+	{"a ? b : (c | d)", "a ? b : (c | d)"},
+	{"sort_by(@, &foo.bar)", "sort_by(@, &foo.bar)"},
+	{"let $a = foo, $b = bar in $a.x", "let $a = foo, $b = bar in $a.x"},
+	{"(let $a = foo in $a) | b", "(let $a = foo in $a) | b"},
+	{"$.foo", "$.foo"},
+}
+
+func TestASTNodeExpression(t *testing.T) {
+	assert := assert.New(t)
+	parser := NewParser()
+	for _, tt := range printerTests {
+		parsed, err := parser.Parse(tt.expression)
+		if !assert.Nil(err, tt.expression) {
+			continue
+		}
+		assert.Equal(tt.expected, parsed.Expression(), tt.expression)
+		reparsed, err := parser.Parse(parsed.Expression())
+		if assert.Nil(err, tt.expression) {
+			assert.Equal(withoutSpans(parsed), withoutSpans(reparsed), tt.expression)
+		}
+	}
+}
+
+func TestASTNodeStringIsPrettyPrint(t *testing.T) {
+	assert := assert.New(t)
+	parsed, err := NewParser().Parse("foo.bar")
+	assert.Nil(err)
+	assert.Equal(parsed.PrettyPrint(0), parsed.String())
+	assert.Equal("foo.bar", parsed.Expression())
+}
+
+func TestASTNodeExpressionAfterModification(t *testing.T) {
+	assert := assert.New(t)
+	parsed, err := NewParser().Parse("foo[?a == `1`]")
+	assert.Nil(err)
+	parsed.Children[2].Children[1] = ASTNode{NodeType: ASTLiteral, Value: "x"}
+	parsed.Children[2] = ASTNode{
+		NodeType: ASTOrExpression,
+		Children: []ASTNode{parsed.Children[2], {NodeType: ASTField, Value: "b"}},
+	}
+	assert.Equal("foo[?a == 'x' || b]", parsed.Expression())
+}
+
+func TestASTNodeExpressionEscapesBackticks(t *testing.T) {
+	assert := assert.New(t)
+	node := ASTNode{NodeType: ASTLiteral, Value: []interface{}{"a`b\\"}}
+	assert.Equal("`[\"a\\u0060b\\\\\"]`", node.Expression())
+	parsed, err := NewParser().Parse(node.Expression())
+	if assert.Nil(err) {
+		assert.Equal(node.Value, parsed.Value)
+	}
+}
+
+func TestComplianceExpressionsRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	files, err := filepath.Glob("compliance/*.json")
+	assert.Nil(err)
+	parser := NewParser()
+	for _, filename := range files {
+		data, err := ioutil.ReadFile(filename)
+		if !assert.Nil(err) {
+			continue
+		}
+		var suites []TestSuite
+		if !assert.Nil(json.Unmarshal(data, &suites), filename) {
+			continue
+		}
+		for _, suite := range suites {
+			for _, testcase := range suite.TestCases {
+				parsed, err := parser.Parse(testcase.Expression)
+				if err != nil {
+					continue
+				}
+				printed := parsed.Expression()
+				reparsed, err := parser.Parse(printed)
+				if assert.Nil(err, "%s: %s printed as %s", filename, testcase.Expression, printed) {
+					assert.Equal(withoutSpans(parsed), withoutSpans(reparsed), "%s: %s printed as %s", filename, testcase.Expression, printed)
+				}
+			}
+		}
+	}
+}
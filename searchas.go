@@ -0,0 +1,23 @@
+//go:build go1.21
+// +build go1.21
+
+// Type parameters need Go 1.18, but go.mod declares an older version of
+// the language, which only Go 1.21 and later upgrade for the files
+// constrained to newer versions.
+
+package jmespath
+
+// SearchAs evaluates a JMESPath expression against input data and returns
+// the result converted to a T, as described for JMESPath.SearchInto.
+func SearchAs[T any](expression string, data interface{}) (T, error) {
+	var result T
+	err := SearchInto(expression, data, &result)
+	return result, err
+}
+
+// SearchCompiledAs is like SearchAs but evaluates a compiled expression.
+func SearchCompiledAs[T any](jp *JMESPath, data interface{}) (T, error) {
+	var result T
+	err := jp.SearchInto(data, &result)
+	return result, err
+}
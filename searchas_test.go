@@ -0,0 +1,25 @@
+//go:build go1.21
+// +build go1.21
+
+package jmespath
+
+import (
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+func TestSearchAs(t *testing.T) {
+	assert := assert.New(t)
+	data := decodeJSON(t, `{"users": [{"user_id": 42, "NAME": "alice", "tags": ["a", "b"]}]}`)
+	tags, err := SearchAs[[]string]("users[0].tags", data)
+	assert.Nil(err)
+	assert.Equal([]string{"a", "b"}, tags)
+
+	user, err := SearchCompiledAs[*intoUser](MustCompile("users[0]"), data)
+	assert.Nil(err)
+	assert.Equal(int64(42), user.ID)
+
+	_, err = SearchAs[int]("users[0].NAME", data)
+	assert.NotNil(err)
+}
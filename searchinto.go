@@ -0,0 +1,381 @@
+package jmespath
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodeError is returned by SearchInto when a value of the result can't
+// be stored in the Go value it corresponds to.
+type DecodeError struct {
+	// Path is an expression selecting the value in the result, such as
+	// "items[2].id". It is empty for the result itself.
+	Path   string
+	Actual JpType
+	// Type is the Go type the value can't be stored in.
+	Type reflect.Type
+	// Reason tells why a value of a matching type can't be stored, such
+	// as a number out of the range of an integer type. It is empty if
+	// the types don't match.
+	Reason string
+}
+
+func (e *DecodeError) Error() string {
+	location := ""
+	if e.Path != "" {
+		location = " at " + e.Path
+	}
+	msg := fmt.Sprintf("cannot decode %s%s into Go value of type %s", e.Actual, location, e.Type)
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+// SearchInto evaluates the expression against data and stores the result
+// in the value pointed to by out, which must be a non-nil pointer. The
+// result is converted following the rules of json.Unmarshal: objects are
+// stored in structs, using the json tags of their fields, or in maps,
+// arrays in slices and arrays, and numbers in any numeric type they fit
+// in. Types implementing json.Unmarshaler or encoding.TextUnmarshaler
+// decode their own values. Values of the result that can be assigned as
+// they are, such as values of data, are not copied.
+//
+// A *DecodeError is returned if a value of the result can't be stored in
+// the corresponding Go value, which may then be partially filled in.
+func (jp *JMESPath) SearchInto(data interface{}, out interface{}) error {
+	target := reflect.ValueOf(out)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("SearchInto needs a non-nil pointer, got %T", out)
+	}
+	result, err := jp.Search(data)
+	if err != nil {
+		return err
+	}
+	return decodeResult(result, target.Elem(), nil)
+}
+
+// SearchInto evaluates a JMESPath expression against input data and
+// stores the result in the value pointed to by out, as described for
+// JMESPath.SearchInto.
+func SearchInto(expression string, data interface{}, out interface{}) error {
+	jp, err := defaultCache.Compile(expression)
+	if err != nil {
+		return err
+	}
+	return jp.SearchInto(data, out)
+}
+
+// decodePath is the location of a value in a search result. It is only
+// turned into text when a value can't be decoded.
+type decodePath struct {
+	parent  *decodePath
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (p *decodePath) String() string {
+	var parts []*decodePath
+	for ; p != nil; p = p.parent {
+		parts = append(parts, p)
+	}
+	var b strings.Builder
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i].isIndex {
+			b.WriteString("[" + strconv.Itoa(parts[i].index) + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(quoteIdentifier(parts[i].key))
+	}
+	return b.String()
+}
+
+var (
+	jsonNumberType      = reflect.TypeOf(json.Number(""))
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// The reasons of DecodeErrors.
+const (
+	reasonNotAnInteger      = "not an integer"
+	reasonOutOfRange        = "out of range"
+	reasonBase64            = "invalid base64 data"
+	reasonInvalidMapKey     = "invalid key for map"
+	reasonUnsupportedMapKey = "unsupported map key type"
+	reasonEmbeddedNilPtr    = "embedded pointer to unexported struct is nil"
+)
+
+func decodeError(value interface{}, dst reflect.Value, path *decodePath, reason string) error {
+	return &DecodeError{Path: path.String(), Actual: jpTypeOf(value), Type: dst.Type(), Reason: reason}
+}
+
+// decodeResult stores value in dst, which must be settable.
+func decodeResult(value interface{}, dst reflect.Value, path *decodePath) error {
+	if value == nil {
+		switch dst.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		// Like json.Unmarshal, null leaves other values unchanged
+		// unless they decode it themselves.
+		if dst.CanAddr() && dst.Addr().Type().Implements(jsonUnmarshalerType) {
+			return dst.Addr().Interface().(json.Unmarshaler).UnmarshalJSON([]byte("null"))
+		}
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Type().AssignableTo(dst.Type()) {
+		dst.Set(rv)
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.Interface:
+		return decodeError(value, dst, path, "")
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return decodeResult(value, dst.Elem(), path)
+	}
+	if dst.CanAddr() {
+		switch u := dst.Addr().Interface().(type) {
+		case json.Unmarshaler:
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			return u.UnmarshalJSON(data)
+		case encoding.TextUnmarshaler:
+			if s, ok := value.(string); ok {
+				return u.UnmarshalText([]byte(s))
+			}
+		}
+	}
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return decodeResult(nil, dst, path)
+		}
+		return decodeResult(rv.Elem().Interface(), dst, path)
+	}
+
+	switch dst.Kind() {
+	case reflect.Bool:
+		if rv.Kind() == reflect.Bool {
+			dst.SetBool(rv.Bool())
+			return nil
+		}
+	case reflect.String:
+		n, ok := toNumber(value)
+		if dst.Type() == jsonNumberType && ok {
+			dst.SetString(n.String())
+			return nil
+		}
+		if rv.Kind() == reflect.String && !ok {
+			dst.SetString(rv.String())
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := toNumber(value); ok {
+			i, reason := n.int()
+			if reason == "" && dst.OverflowInt(i) {
+				reason = reasonOutOfRange
+			}
+			if reason != "" {
+				return decodeError(value, dst, path, reason)
+			}
+			dst.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, ok := toNumber(value); ok {
+			u, reason := n.uint()
+			if reason == "" && dst.OverflowUint(u) {
+				reason = reasonOutOfRange
+			}
+			if reason != "" {
+				return decodeError(value, dst, path, reason)
+			}
+			dst.SetUint(u)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := toFloat(value); ok {
+			if dst.OverflowFloat(f) {
+				return decodeError(value, dst, path, reasonOutOfRange)
+			}
+			dst.SetFloat(f)
+			return nil
+		}
+	case reflect.Slice:
+		if s, ok := value.(string); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			// Like json.Unmarshal, byte slices are decoded from
+			// base64 strings.
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return decodeError(value, dst, path, reasonBase64)
+			}
+			dst.SetBytes(b)
+			return nil
+		}
+		if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			if rv.Kind() == reflect.Slice && rv.IsNil() {
+				dst.Set(reflect.Zero(dst.Type()))
+				return nil
+			}
+			decoded := reflect.MakeSlice(dst.Type(), rv.Len(), rv.Len())
+			if err := decodeElements(rv, decoded, path); err != nil {
+				return err
+			}
+			dst.Set(decoded)
+			return nil
+		}
+	case reflect.Array:
+		if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			if err := decodeElements(rv, dst, path); err != nil {
+				return err
+			}
+			// Like json.Unmarshal, the elements missing from the
+			// result are zeroed.
+			for i := rv.Len(); i < dst.Len(); i++ {
+				dst.Index(i).Set(reflect.Zero(dst.Type().Elem()))
+			}
+			return nil
+		}
+	case reflect.Map:
+		if isObject(rv) {
+			return decodeMap(rv, dst, path)
+		}
+	case reflect.Struct:
+		if isObject(rv) {
+			return decodeStruct(rv, dst, path)
+		}
+	}
+	return decodeError(value, dst, path, "")
+}
+
+// decodeElements decodes the elements of the slice or array src into the
+// elements of the slice or array dst, as far as they both go.
+func decodeElements(src, dst reflect.Value, path *decodePath) error {
+	for i := 0; i < src.Len() && i < dst.Len(); i++ {
+		element := &decodePath{parent: path, index: i, isIndex: true}
+		if err := decodeResult(src.Index(i).Interface(), dst.Index(i), element); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isObject determines if a value, which is not a pointer, is a JMESPath
+// object: a map with string keys or a struct.
+func isObject(v reflect.Value) bool {
+	return v.Kind() == reflect.Struct || v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String
+}
+
+// forEachMember calls fn with the name and value of each member of the
+// object src, as encoding/json would encode it.
+func forEachMember(src reflect.Value, fn func(name string, value interface{}) error) error {
+	if src.Kind() == reflect.Map {
+		iter := src.MapRange()
+		for iter.Next() {
+			if err := fn(iter.Key().String(), iter.Value().Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, field := range cachedStructFields(src.Type()).list {
+		value, ok := field.value(src)
+		if !ok {
+			continue
+		}
+		if err := fn(field.name, value.Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeMap(src, dst reflect.Value, path *decodePath) error {
+	mapType := dst.Type()
+	keyType := mapType.Key()
+	switch keyType.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !reflect.PtrTo(keyType).Implements(textUnmarshalerType) {
+			return decodeError(src.Interface(), dst, path, reasonUnsupportedMapKey)
+		}
+	}
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMap(mapType))
+	}
+	return forEachMember(src, func(name string, value interface{}) error {
+		member := &decodePath{parent: path, key: name}
+		key := reflect.New(keyType).Elem()
+		if u, ok := key.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := u.UnmarshalText([]byte(name)); err != nil {
+				return decodeError(src.Interface(), dst, member, reasonInvalidMapKey)
+			}
+		} else {
+			switch keyType.Kind() {
+			case reflect.String:
+				key.SetString(name)
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				i, err := strconv.ParseInt(name, 10, 64)
+				if err != nil || key.OverflowInt(i) {
+					return decodeError(src.Interface(), dst, member, reasonInvalidMapKey)
+				}
+				key.SetInt(i)
+			default:
+				u, err := strconv.ParseUint(name, 10, 64)
+				if err != nil || key.OverflowUint(u) {
+					return decodeError(src.Interface(), dst, member, reasonInvalidMapKey)
+				}
+				key.SetUint(u)
+			}
+		}
+		element := reflect.New(mapType.Elem()).Elem()
+		if err := decodeResult(value, element, member); err != nil {
+			return err
+		}
+		dst.SetMapIndex(key, element)
+		return nil
+	})
+}
+
+func decodeStruct(src, dst reflect.Value, path *decodePath) error {
+	fields := cachedStructFields(dst.Type())
+	return forEachMember(src, func(name string, value interface{}) error {
+		field := fields.lookup(name)
+		if field == nil {
+			return nil
+		}
+		member := &decodePath{parent: path, key: name}
+		v := dst
+		for _, i := range field.index {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					if !v.CanSet() {
+						return decodeError(value, v, member, reasonEmbeddedNilPtr)
+					}
+					v.Set(reflect.New(v.Type().Elem()))
+				}
+				v = v.Elem()
+			}
+			v = v.Field(i)
+		}
+		return decodeResult(value, v, member)
+	})
+}
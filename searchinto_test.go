@@ -0,0 +1,158 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+type intoAddress struct {
+	City string `json:"city"`
+	Zip  *int   `json:"zip"`
+}
+
+type intoUser struct {
+	ID       int64             `json:"user_id"`
+	Name     string            `json:"name"`
+	Admin    bool              `json:"admin"`
+	Score    float32           `json:"score"`
+	Tags     []string          `json:"tags"`
+	Address  *intoAddress      `json:"address"`
+	Labels   map[string]string `json:"labels"`
+	Counts   map[int]uint8     `json:"counts"`
+	Created  time.Time         `json:"created"`
+	Avatar   []byte            `json:"avatar"`
+	Position [2]float64        `json:"position"`
+	Raw      interface{}       `json:"raw"`
+	Ignored  string            `json:"-"`
+}
+
+func TestSearchIntoStruct(t *testing.T) {
+	assert := assert.New(t)
+	var user intoUser
+	data := decodeJSON(t, `{"users": [{
+		"user_id": 42,
+		"NAME": "alice",
+		"admin": true,
+		"score": 1.5,
+		"tags": ["a", "b"],
+		"address": {"city": "Paris", "zip": 75001},
+		"labels": {"team": "core"},
+		"counts": {"1": 2, "3": 4},
+		"created": "2021-02-03T04:05:06Z",
+		"avatar": "aGk=",
+		"position": [1, 2, 3],
+		"raw": {"x": [1]},
+		"Ignored": "x",
+		"extra": "ignored"
+	}]}`)
+	err := MustCompile("users[0]").SearchInto(data, &user)
+	assert.Nil(err)
+	zip := 75001
+	assert.Equal(intoUser{
+		ID:       42,
+		Name:     "alice",
+		Admin:    true,
+		Score:    1.5,
+		Tags:     []string{"a", "b"},
+		Address:  &intoAddress{City: "Paris", Zip: &zip},
+		Labels:   map[string]string{"team": "core"},
+		Counts:   map[int]uint8{1: 2, 3: 4},
+		Created:  time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC),
+		Avatar:   []byte("hi"),
+		Position: [2]float64{1, 2},
+		Raw:      map[string]interface{}{"x": []interface{}{1.0}},
+	}, user)
+}
+
+func TestSearchIntoScalarsAndCollections(t *testing.T) {
+	assert := assert.New(t)
+	data := decodeJSON(t, `{"users": [{"user_id": 42, "NAME": "alice", "score": 1.5, "labels": {"team": "core"}}]}`)
+
+	var names []string
+	assert.Nil(SearchInto("users[*].NAME", data, &names))
+	assert.Equal([]string{"alice"}, names)
+
+	var id uint16
+	assert.Nil(SearchInto("users[0].user_id", data, &id))
+	assert.Equal(uint16(42), id)
+
+	var number json.Number
+	assert.Nil(SearchInto("users[0].score", data, &number))
+	assert.Equal(json.Number("1.5"), number)
+
+	var labels map[string]interface{}
+	assert.Nil(SearchInto("users[0].labels", data, &labels))
+	assert.Equal(map[string]interface{}{"team": "core"}, labels)
+
+	// Null sets pointers to nil and leaves other values unchanged.
+	name := "unchanged"
+	pointer := &name
+	assert.Nil(SearchInto("missing", data, &pointer))
+	assert.Nil(pointer)
+	assert.Nil(SearchInto("missing", data, &name))
+	assert.Equal("unchanged", name)
+
+	// Values of the data that have the right type are stored as they are.
+	people := []person{{Name: "d", Age: 31}}
+	var selected []person
+	assert.Nil(SearchInto("@", people, &selected))
+	assert.Equal(people, selected)
+	var ages []int
+	assert.Nil(SearchInto("[*].Age", people, &ages))
+	assert.Equal([]int{31}, ages)
+
+	// Structs are decoded like the objects they are searched as.
+	var address map[string]interface{}
+	assert.Nil(SearchInto("@", intoAddress{City: "Lyon"}, &address))
+	assert.Equal(map[string]interface{}{"city": "Lyon", "zip": (*int)(nil)}, address)
+}
+
+func TestSearchIntoErrors(t *testing.T) {
+	assert := assert.New(t)
+	data := decodeJSON(t, `{"users": [{
+		"NAME": "alice",
+		"score": 1.5,
+		"tags": ["a", "b"],
+		"address": {"zip": 75001},
+		"labels": {"team": "core"}
+	}]}`)
+	tests := []struct {
+		expression string
+		out        interface{}
+		message    string
+	}{
+		{"users[0].NAME", new(int), "cannot decode string into Go value of type int"},
+		{"users", new([]struct{ Tags []int }), "cannot decode string at [0].tags[0] into Go value of type int"},
+		{"users[0].score", new(int), "cannot decode number into Go value of type int: not an integer"},
+		{"users[0].address.zip", new(int16), "cannot decode number into Go value of type int16: out of range"},
+		{"`-1`", new(uint), "cannot decode number into Go value of type uint: out of range"},
+		{"users[0]", new(map[bool]string), "cannot decode object into Go value of type map[bool]string: unsupported map key type"},
+		{"users[0].labels", new(map[int]string), "cannot decode object at team into Go value of type map[int]string: invalid key for map"},
+		{"users[0].{\"a b\": tags}", new(map[string]bool), "cannot decode array at \"a b\" into Go value of type bool"},
+		{"users[0].NAME", new([]byte), "cannot decode string into Go value of type []uint8: invalid base64 data"},
+		{"users[0].score", new(string), "cannot decode number into Go value of type string"},
+		{"users[0].NAME", new(error), "cannot decode string into Go value of type error"},
+	}
+	for _, tt := range tests {
+		err := SearchInto(tt.expression, data, tt.out)
+		var decodeErr *DecodeError
+		if assert.True(errors.As(err, &decodeErr), tt.expression) {
+			assert.Equal(tt.message, err.Error(), tt.expression)
+		}
+	}
+
+	var result interface{}
+	assert.NotNil(SearchInto("users[0]", data, result))
+	assert.NotNil(SearchInto("users[0]", data, (*int)(nil)))
+	assert.NotNil(SearchInto("abs(users)", data, &result))
+	assert.NotNil(SearchInto("users[", data, &result))
+	var huge float32
+	err := SearchInto("@", math.MaxFloat64, &huge)
+	assert.True(strings.HasSuffix(err.Error(), "out of range"))
+}
@@ -0,0 +1,465 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+)
+
+/* SearchJSON decodes only the parts of a JSON document that an expression
+   needs. When an expression is compiled, its AST is analyzed to find out
+   which members of the objects and which elements of the arrays of the
+   document it can reach, which is recorded in a tree of field masks. The
+   document is then decoded following the masks: values that no mask
+   reaches are skipped, objects and arrays that are only looked into are
+   decoded with only the members and elements that are reached, and values
+   that the expression may return or compare are decoded entirely.
+
+   The members and elements that are skipped are decoded as nil, rather
+   than left out, so that testing whether an object or array is empty
+   gives the same result as with the whole document.
+*/
+
+// fieldMask records the parts of a JSON value that an expression needs.
+type fieldMask struct {
+	// all is set when the whole value is needed.
+	all bool
+	// fields are the masks of the members of an object, by name.
+	fields map[string]*fieldMask
+	// values is the mask of every member of an object.
+	values *fieldMask
+	// indexes are the masks of the elements of an array, by index.
+	// Negative indexes count from the end of the array.
+	indexes map[int]*fieldMask
+	// elements is the mask of every element of an array.
+	elements *fieldMask
+}
+
+func (m *fieldMask) field(name string) *fieldMask {
+	if m.fields == nil {
+		m.fields = make(map[string]*fieldMask)
+	}
+	if m.fields[name] == nil {
+		m.fields[name] = &fieldMask{}
+	}
+	return m.fields[name]
+}
+
+func (m *fieldMask) value() *fieldMask {
+	if m.values == nil {
+		m.values = &fieldMask{}
+	}
+	return m.values
+}
+
+func (m *fieldMask) index(index int) *fieldMask {
+	if m.indexes == nil {
+		m.indexes = make(map[int]*fieldMask)
+	}
+	if m.indexes[index] == nil {
+		m.indexes[index] = &fieldMask{}
+	}
+	return m.indexes[index]
+}
+
+func (m *fieldMask) element() *fieldMask {
+	if m.elements == nil {
+		m.elements = &fieldMask{}
+	}
+	return m.elements
+}
+
+// maskView describes where the result of an expression comes from. The
+// result is either one of the values of masks, or an array created by the
+// expression whose elements are described by elements. A nil view is the
+// view of results that don't come from the document.
+type maskView struct {
+	masks    []*fieldMask
+	elements *maskView
+}
+
+func (v *maskView) field(name string) *maskView {
+	if v == nil {
+		return nil
+	}
+	result := &maskView{}
+	for _, m := range v.masks {
+		result.masks = append(result.masks, m.field(name))
+	}
+	return result
+}
+
+func (v *maskView) values() *maskView {
+	if v == nil {
+		return nil
+	}
+	result := &maskView{}
+	for _, m := range v.masks {
+		result.masks = append(result.masks, m.value())
+	}
+	return result
+}
+
+func (v *maskView) index(index int) *maskView {
+	if v == nil {
+		return nil
+	}
+	result := &maskView{}
+	for _, m := range v.masks {
+		result.masks = append(result.masks, m.index(index))
+	}
+	return unionViews(result, v.elements)
+}
+
+// element returns the view of the elements of the arrays of v.
+func (v *maskView) element() *maskView {
+	if v == nil {
+		return nil
+	}
+	result := &maskView{}
+	for _, m := range v.masks {
+		result.masks = append(result.masks, m.element())
+	}
+	return unionViews(result, v.elements)
+}
+
+// markAll records that the whole value of v is needed.
+func (v *maskView) markAll() {
+	if v == nil {
+		return
+	}
+	for _, m := range v.masks {
+		m.all = true
+	}
+	v.elements.markAll()
+}
+
+func unionViews(a, b *maskView) *maskView {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &maskView{
+		masks:    append(append([]*fieldMask{}, a.masks...), b.masks...),
+		elements: unionViews(a.elements, b.elements),
+	}
+}
+
+type viewScope struct {
+	variables map[string]*maskView
+	parent    *viewScope
+}
+
+func (s *viewScope) lookup(name string) *maskView {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.variables[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// maskAnalyzer finds the parts of a document that an AST needs.
+type maskAnalyzer struct {
+	root *maskView
+}
+
+// fieldMaskOf returns the mask of the parts of a document that ast needs.
+func fieldMaskOf(ast ASTNode) *fieldMask {
+	root := &fieldMask{}
+	a := &maskAnalyzer{root: &maskView{masks: []*fieldMask{root}}}
+	a.view(ast, a.root, nil).markAll()
+	return root
+}
+
+// view returns the view of the result of node, when node is evaluated
+// against a value from cur.
+func (a *maskAnalyzer) view(node ASTNode, cur *maskView, scope *viewScope) *maskView {
+	switch node.NodeType {
+	case ASTIdentity, ASTCurrentNode:
+		return cur
+	case ASTField:
+		return cur.field(node.Value.(string))
+	case ASTIndex:
+		return cur.index(node.Value.(int))
+	case ASTSlice:
+		return &maskView{elements: cur.element()}
+	case ASTLiteral:
+		return nil
+	case ASTRootNode:
+		return a.root
+	case ASTVariable:
+		return scope.lookup(node.Value.(string))
+	case ASTSubexpression, ASTIndexExpression, ASTPipe:
+		return a.view(node.Children[1], a.view(node.Children[0], cur, scope), scope)
+	case ASTProjection:
+		elements := a.view(node.Children[0], cur, scope).element()
+		return &maskView{elements: a.view(node.Children[1], elements, scope)}
+	case ASTValueProjection:
+		values := a.view(node.Children[0], cur, scope).values()
+		return &maskView{elements: a.view(node.Children[1], values, scope)}
+	case ASTFilterProjection, ASTFirstMatch:
+		elements := a.view(node.Children[0], cur, scope).element()
+		// Only the truth value of the condition is used, which doesn't
+		// need the values that are skipped.
+		a.view(node.Children[2], elements, scope)
+		result := a.view(node.Children[1], elements, scope)
+		if node.NodeType == ASTFirstMatch {
+			return result
+		}
+		return &maskView{elements: result}
+	case ASTFlatten:
+		elements := a.view(node.Children[0], cur, scope).element()
+		return &maskView{elements: unionViews(elements, elements.element())}
+	case ASTComparator, ASTArithmeticExpression, ASTArithmeticUnaryExpression:
+		for _, child := range node.Children {
+			a.view(child, cur, scope).markAll()
+		}
+		return nil
+	case ASTNotExpression:
+		a.view(node.Children[0], cur, scope)
+		return nil
+	case ASTOrExpression, ASTAndExpression:
+		return unionViews(a.view(node.Children[0], cur, scope), a.view(node.Children[1], cur, scope))
+	case ASTTernaryExpression:
+		a.view(node.Children[0], cur, scope)
+		return unionViews(a.view(node.Children[1], cur, scope), a.view(node.Children[2], cur, scope))
+	case ASTMultiSelectList:
+		for _, child := range node.Children {
+			a.view(child, cur, scope).markAll()
+		}
+		return nil
+	case ASTMultiSelectHash:
+		for _, child := range node.Children {
+			a.view(child.Children[0], cur, scope).markAll()
+		}
+		return nil
+	case ASTFunctionExpression:
+		for _, arg := range node.Children {
+			if arg.NodeType == ASTExpRef {
+				// Exprefs are applied to the other arguments, which
+				// are needed entirely, but can refer to the root and
+				// to variables.
+				a.view(arg.Children[0], nil, scope).markAll()
+				continue
+			}
+			a.view(arg, cur, scope).markAll()
+		}
+		return nil
+	case ASTLetExpression:
+		last := len(node.Children) - 1
+		inner := &viewScope{variables: make(map[string]*maskView, last), parent: scope}
+		for _, binding := range node.Children[:last] {
+			inner.variables[binding.Value.(string)] = a.view(binding.Children[0], cur, scope)
+		}
+		return a.view(node.Children[last], cur, inner)
+	}
+	// Nodes that aren't known need the whole document.
+	a.root.markAll()
+	return nil
+}
+
+// decodeMasked decodes the JSON value in data, which must be valid,
+// following masks.
+func decodeMasked(data []byte, masks []*fieldMask) (interface{}, error) {
+	needed := false
+	for _, m := range masks {
+		if m.all {
+			needed = true
+			break
+		}
+	}
+	if needed || (data[0] != '{' && data[0] != '[') {
+		var value interface{}
+		err := json.Unmarshal(data, &value)
+		return value, err
+	}
+	if data[0] == '{' {
+		return decodeObject(data, masks)
+	}
+	return decodeArray(data, masks)
+}
+
+func decodeObject(data []byte, masks []*fieldMask) (interface{}, error) {
+	object := map[string]interface{}{}
+	i := skipSpace(data, 1)
+	for data[i] != '}' {
+		keyEnd := skipString(data, i)
+		var key string
+		if err := json.Unmarshal(data[i:keyEnd], &key); err != nil {
+			return nil, err
+		}
+		i = skipSpace(data, skipSpace(data, keyEnd)+1)
+		end := skipValue(data, i)
+		var memberMasks []*fieldMask
+		for _, m := range masks {
+			if field := m.fields[key]; field != nil {
+				memberMasks = append(memberMasks, field)
+			}
+			if m.values != nil {
+				memberMasks = append(memberMasks, m.values)
+			}
+		}
+		object[key] = nil
+		if memberMasks != nil {
+			value, err := decodeMasked(data[i:end], memberMasks)
+			if err != nil {
+				return nil, err
+			}
+			object[key] = value
+		}
+		i = skipSpace(data, end)
+		if data[i] == ',' {
+			i = skipSpace(data, i+1)
+		}
+	}
+	return object, nil
+}
+
+func decodeArray(data []byte, masks []*fieldMask) (interface{}, error) {
+	// The elements are found first, negative indexes need the length
+	// of the array.
+	var starts, ends []int
+	i := skipSpace(data, 1)
+	for data[i] != ']' {
+		end := skipValue(data, i)
+		starts = append(starts, i)
+		ends = append(ends, end)
+		i = skipSpace(data, end)
+		if data[i] == ',' {
+			i = skipSpace(data, i+1)
+		}
+	}
+	array := make([]interface{}, len(starts))
+	for index := range array {
+		var elementMasks []*fieldMask
+		for _, m := range masks {
+			if m.elements != nil {
+				elementMasks = append(elementMasks, m.elements)
+			}
+			if element := m.indexes[index]; element != nil {
+				elementMasks = append(elementMasks, element)
+			}
+			if element := m.indexes[index-len(array)]; element != nil {
+				elementMasks = append(elementMasks, element)
+			}
+		}
+		if elementMasks == nil {
+			continue
+		}
+		value, err := decodeMasked(data[starts[index]:ends[index]], elementMasks)
+		if err != nil {
+			return nil, err
+		}
+		array[index] = value
+	}
+	return array, nil
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\r', '\n':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipString returns the offset following the string starting at i.
+func skipString(data []byte, i int) int {
+	for i++; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return i
+}
+
+// skipValue returns the offset following the value starting at i.
+func skipValue(data []byte, i int) int {
+	switch data[i] {
+	case '"':
+		return skipString(data, i)
+	case '{', '[':
+		depth := 0
+		for i < len(data) {
+			switch data[i] {
+			case '"':
+				i = skipString(data, i)
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+		return i
+	}
+	for i < len(data) {
+		switch data[i] {
+		case ',', '}', ']', ' ', '\t', '\r', '\n':
+			return i
+		}
+		i++
+	}
+	return i
+}
+
+// SearchJSON evaluates the expression against a JSON document. Only the
+// parts of the document that the expression needs are decoded, which is
+// much faster than decoding the whole document when the expression only
+// selects a few values of a large document.
+func (jp *JMESPath) SearchJSON(document []byte) (interface{}, error) {
+	if !json.Valid(document) {
+		// Unmarshal reports what is wrong with the document.
+		var data interface{}
+		return nil, json.Unmarshal(document, &data)
+	}
+	start := skipSpace(document, 0)
+	data, err := decodeMasked(document[start:skipValue(document, start)], []*fieldMask{jp.mask})
+	if err != nil {
+		return nil, err
+	}
+	return jp.Search(data)
+}
+
+// SearchReader is like SearchJSON but reads the document from r.
+func (jp *JMESPath) SearchReader(r io.Reader) (interface{}, error) {
+	document, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return jp.SearchJSON(document)
+}
+
+// SearchJSON is like Search but evaluates the expression against a JSON
+// document, decoding only the parts of the document that the expression
+// needs.
+func SearchJSON(expression string, document []byte) (interface{}, error) {
+	jp, err := defaultCache.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return jp.SearchJSON(document)
+}
+
+// SearchReader is like SearchJSON but reads the document from r.
+func SearchReader(expression string, r io.Reader) (interface{}, error) {
+	jp, err := defaultCache.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return jp.SearchReader(r)
+}
@@ -0,0 +1,146 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+const searchJSONDocument = `{
+	"foo": {"bar": "baz", "skipped": {"a": [1, "]}", {"b": "\"{"}]}},
+	"list": [{"a": 1, "b": [1, 2]}, {"a": 2, "b": [3]}, {"a": 3, "b": []}],
+	"esc\"aped": true,
+	"empty": {},
+	"number": 1.5e3
+}`
+
+var searchJSONTests = []struct {
+	expression string
+	decoded    string
+}{
+	{"foo.bar", `{"foo": {"bar": "baz", "skipped": null}, "list": null, "esc\"aped": null, "empty": null, "number": null}`},
+	{"list[-1].a", `{"foo": null, "list": [null, null, {"a": 3, "b": null}], "esc\"aped": null, "empty": null, "number": null}`},
+	{"list[?a > `1`].b[0]", `{"foo": null, "list": [{"a": 1, "b": [1, null]}, {"a": 2, "b": [3]}, {"a": 3, "b": []}], "esc\"aped": null, "empty": null, "number": null}`},
+	{"\"esc\\\"aped\" && number", `{"foo": null, "list": null, "esc\"aped": true, "empty": null, "number": 1500}`},
+	{"sort(keys(@))", searchJSONDocument},
+	{"foo.*.a", `{"foo": {"bar": "baz", "skipped": {"a": [1, "]}", {"b": "\"{"}]}}, "list": null, "esc\"aped": null, "empty": null, "number": null}`},
+	{"length(list[1:].b[])", `{"foo": null, "list": [{"a": null, "b": [1, 2]}, {"a": null, "b": [3]}, {"a": null, "b": []}], "esc\"aped": null, "empty": null, "number": null}`},
+	{"let $x = list in $x[0].a", `{"foo": null, "list": [{"a": 1, "b": null}, null, null], "esc\"aped": null, "empty": null, "number": null}`},
+	{"[?a] | [0]", `{"foo": null, "list": null, "esc\"aped": null, "empty": null, "number": null}`},
+}
+
+func TestSearchJSONDecodesOnlyWhatIsNeeded(t *testing.T) {
+	assert := assert.New(t)
+	var document interface{}
+	assert.Nil(json.Unmarshal([]byte(searchJSONDocument), &document))
+	for _, tt := range searchJSONTests {
+		compiled := MustCompile(tt.expression)
+		var expected interface{}
+		assert.Nil(json.Unmarshal([]byte(tt.decoded), &expected), tt.expression)
+		decoded, err := decodeMasked([]byte(strings.TrimSpace(searchJSONDocument)), []*fieldMask{compiled.mask})
+		assert.Nil(err, tt.expression)
+		assert.Equal(expected, decoded, tt.expression)
+
+		result, err := compiled.SearchJSON([]byte(searchJSONDocument))
+		assert.Nil(err, tt.expression)
+		expectedResult, err := compiled.Search(document)
+		assert.Nil(err, tt.expression)
+		assert.Equal(expectedResult, result, tt.expression)
+	}
+}
+
+func TestSearchJSONInvalidDocuments(t *testing.T) {
+	assert := assert.New(t)
+	for _, document := range []string{"", "{", `{"foo": 1,}`, `{"foo": 1} 2`, "[1, 2"} {
+		var data interface{}
+		expected := json.Unmarshal([]byte(document), &data)
+		_, err := SearchJSON("foo", []byte(document))
+		assert.Equal(expected, err, document)
+	}
+	_, err := SearchJSON("foo[", []byte("{}"))
+	assert.NotNil(err)
+}
+
+func TestSearchReader(t *testing.T) {
+	assert := assert.New(t)
+	result, err := SearchReader("list[*].a", strings.NewReader(searchJSONDocument))
+	assert.Nil(err)
+	assert.Equal([]interface{}{1.0, 2.0, 3.0}, result)
+	result, err = SearchReader("[0]", strings.NewReader(" [true] \n"))
+	assert.Nil(err)
+	assert.Equal(true, result)
+}
+
+func TestSearchJSONCompliance(t *testing.T) {
+	assert := assert.New(t)
+	files, err := filepath.Glob("compliance/*.json")
+	assert.Nil(err)
+	for _, filename := range files {
+		data, err := ioutil.ReadFile(filename)
+		if !assert.Nil(err) {
+			continue
+		}
+		var suites []TestSuite
+		if !assert.Nil(json.Unmarshal(data, &suites), filename) {
+			continue
+		}
+		for _, suite := range suites {
+			document, err := json.Marshal(suite.Given)
+			if !assert.Nil(err, filename) {
+				continue
+			}
+			for _, testcase := range suite.TestCases {
+				compiled, err := Compile(testcase.Expression)
+				if err != nil {
+					continue
+				}
+				// sort_by sorts its input in place, each search gets
+				// its own copy of the document.
+				var given interface{}
+				assert.Nil(json.Unmarshal(document, &given))
+				expected, expectedErr := compiled.Search(given)
+				actual, actualErr := compiled.SearchJSON(document)
+				assert.Equal(expectedErr, actualErr, "%s: %s", filename, testcase.Expression)
+				assert.Equal(expected, actual, "%s: %s", filename, testcase.Expression)
+			}
+		}
+	}
+}
+
+func largeJSONDocument() []byte {
+	items := make([]string, 10000)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"id": %d, "name": "item %d", "tags": ["a", "b", "c"], "nested": {"value": %d}}`, i, i, i)
+	}
+	return []byte(`{"meta": {"count": 10000, "next": "abc"}, "items": [` + strings.Join(items, ",") + `]}`)
+}
+
+func BenchmarkSearchJSON(b *testing.B) {
+	document := largeJSONDocument()
+	compiled := MustCompile("[meta.count, items[-1].id, meta.next]")
+	b.Run("Unmarshal", func(b *testing.B) {
+		b.SetBytes(int64(len(document)))
+		for i := 0; i < b.N; i++ {
+			var data interface{}
+			if err := json.Unmarshal(document, &data); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := compiled.Search(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("SearchJSON", func(b *testing.B) {
+		b.SetBytes(int64(len(document)))
+		for i := 0; i < b.N; i++ {
+			if _, err := compiled.SearchJSON(document); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
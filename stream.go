@@ -0,0 +1,189 @@
+package jmespath
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Stream evaluates an expression against each of the JSON records read
+// from a reader, one record at a time, so that inputs of any size can be
+// searched in constant memory. Records are read either from
+// newline-delimited JSON, one record per line, or from a JSON array, one
+// record per element. The input is an array unless its first line is a
+// complete value followed by other records, so an input holding a single
+// array is an array of records.
+//
+// A Stream is used like a bufio.Scanner:
+//
+//	stream := compiled.Stream(r)
+//	for stream.Next() {
+//		result, err := stream.Result()
+//		...
+//	}
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+type Stream struct {
+	jp *JMESPath
+	// lines reads newline-delimited records, decoder the elements of an
+	// array. Only one of them is set once the format is known.
+	lines   *bufio.Reader
+	decoder *json.Decoder
+	started bool
+
+	record int
+	result interface{}
+	// recordErr is the error of the current record, err the error that
+	// ended the stream.
+	recordErr error
+	err       error
+}
+
+// Stream returns a Stream evaluating the expression against each record
+// read from r.
+func (jp *JMESPath) Stream(r io.Reader) *Stream {
+	return &Stream{jp: jp, lines: bufio.NewReader(r), record: -1}
+}
+
+// Next evaluates the expression against the next record. It returns false
+// once all the records have been read, or when the input can't be read any
+// further, in which case Err returns the error.
+func (s *Stream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if !s.started {
+		s.started = true
+		if err := s.start(); err != nil {
+			return s.stop(err)
+		}
+	}
+	var document []byte
+	if s.decoder != nil {
+		if !s.decoder.More() {
+			return s.stop(s.end())
+		}
+		var raw json.RawMessage
+		if err := s.decoder.Decode(&raw); err != nil {
+			return s.stop(err)
+		}
+		document = raw
+	} else {
+		for len(document) == 0 {
+			line, err := s.lines.ReadBytes('\n')
+			if err != nil && (err != io.EOF || len(line) == 0) {
+				return s.stop(err)
+			}
+			document = bytes.TrimSpace(line)
+		}
+	}
+	s.record++
+	s.result, s.recordErr = s.jp.SearchJSON(document)
+	return true
+}
+
+// start finds out whether the records are the elements of an array. The
+// input is newline-delimited if its first value ends on its first line and
+// is followed by other values, and an array otherwise.
+func (s *Stream) start() error {
+	c, err := s.skipSpace()
+	if err != nil || c != '[' {
+		return err
+	}
+	line, err := s.lines.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	rest := s.lines
+	if json.Valid(line) {
+		if _, err := s.skipSpace(); err != io.EOF {
+			if err != nil {
+				return err
+			}
+			// The first line is a record.
+			s.lines = bufio.NewReader(io.MultiReader(bytes.NewReader(line), rest))
+			return nil
+		}
+	}
+	s.decoder = json.NewDecoder(io.MultiReader(bytes.NewReader(line), rest))
+	s.lines = nil
+	_, err = s.decoder.Token()
+	return err
+}
+
+// skipSpace skips the white space at the start of the unread input and
+// returns the next byte, which is left unread.
+func (s *Stream) skipSpace() (byte, error) {
+	for {
+		c, err := s.lines.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return c, s.lines.UnreadByte()
+	}
+}
+
+// end checks that the array of records is followed by nothing else.
+func (s *Stream) end() error {
+	if _, err := s.decoder.Token(); err != nil {
+		return err
+	}
+	if _, err := s.decoder.Token(); err != io.EOF {
+		if err == nil {
+			err = errors.New("invalid JSON after the array of records")
+		}
+		return err
+	}
+	return io.EOF
+}
+
+func (s *Stream) stop(err error) bool {
+	s.err = err
+	s.result, s.recordErr = nil, nil
+	return false
+}
+
+// Record returns the index of the current record, starting at 0. Blank
+// lines are not records.
+func (s *Stream) Record() int {
+	return s.record
+}
+
+// Result returns the result of the expression for the current record, or
+// the error evaluating it. A record that isn't valid JSON is an error of
+// that record, unless it is an element of an array of records, which can't
+// be read any further.
+func (s *Stream) Result() (interface{}, error) {
+	return s.result, s.recordErr
+}
+
+// Err returns the error that ended the stream, or nil if all the records
+// have been read.
+func (s *Stream) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// SearchStream evaluates the expression against each record read from r,
+// as described for Stream, and calls handle with the index and result of
+// each record, or the error evaluating it. SearchStream stops and returns
+// the error returned by handle, if any, or the error reading r.
+func (jp *JMESPath) SearchStream(r io.Reader, handle func(record int, result interface{}, err error) error) error {
+	stream := jp.Stream(r)
+	for stream.Next() {
+		result, err := stream.Result()
+		if err := handle(stream.Record(), result, err); err != nil {
+			return err
+		}
+	}
+	return stream.Err()
+}
@@ -0,0 +1,121 @@
+package jmespath
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+type streamRecord struct {
+	record int
+	result interface{}
+	err    bool
+}
+
+func searchStream(expression, input string) ([]streamRecord, error) {
+	var records []streamRecord
+	err := MustCompile(expression).SearchStream(strings.NewReader(input), func(record int, result interface{}, err error) error {
+		records = append(records, streamRecord{record: record, result: result, err: err != nil})
+		return nil
+	})
+	return records, err
+}
+
+var streamTests = []struct {
+	input    string
+	expected []streamRecord
+}{
+	{"", nil},
+	{"\n \n", nil},
+	{`{"a": 1}`, []streamRecord{{0, 1.0, false}}},
+	{"{\"a\": 1}\r\n\r\n{\"a\": 2}\n", []streamRecord{{0, 1.0, false}, {1, 2.0, false}}},
+	{"{\"a\": 1}\n{\"a\": \n{\"a\": \"x\"}\n", []streamRecord{{0, 1.0, false}, {1, nil, true}, {2, nil, true}}},
+	{`[{"a": 1}, {"a": 2}, {"a": -3}]`, []streamRecord{{0, 1.0, false}, {1, 2.0, false}, {2, 3.0, false}}},
+	{" [ ] ", nil},
+	{`[{"a": "x"}, {"a": 2}]`, []streamRecord{{0, nil, true}, {1, 2.0, false}}},
+}
+
+func TestSearchStream(t *testing.T) {
+	assert := assert.New(t)
+	for _, tt := range streamTests {
+		records, err := searchStream("abs(a)", tt.input)
+		assert.Nil(err, tt.input)
+		assert.Equal(tt.expected, records, tt.input)
+	}
+
+	// Newline-delimited records can be arrays, the input is an array of
+	// records only if its first line isn't followed by other records.
+	for _, input := range []string{
+		"[{\"a\": 1}]\n[{\"a\": 2}]",
+		"\n[{\"a\": 1}]\n\n[{\"a\": 2}]\n",
+		"[{\"a\": 1}]\r\n[{\"a\": 2}]",
+	} {
+		records, err := searchStream("[0].a", input)
+		assert.Nil(err, input)
+		assert.Equal([]streamRecord{{0, 1.0, false}, {1, 2.0, false}}, records, input)
+	}
+	for _, input := range []string{
+		"[[{\"a\": 1}], [{\"a\": 2}]]\n",
+		"[\n  [{\"a\": 1}],\n  [{\"a\": 2}]\n]\n",
+		"[[{\"a\": 1}],\n[{\"a\": 2}]]",
+	} {
+		records, err := searchStream("[0].a", input)
+		assert.Nil(err, input)
+		assert.Equal([]streamRecord{{0, 1.0, false}, {1, 2.0, false}}, records, input)
+	}
+}
+
+func TestSearchStreamInvalidArrays(t *testing.T) {
+	assert := assert.New(t)
+	for _, input := range []string{`[{"a": 1}, {"a": }]`, `[{"a": 1}`, `[{"a": 1}] {}`, `[{"a": 1}] x`} {
+		records, err := searchStream("a", input)
+		assert.NotNil(err, input)
+		assert.Equal([]streamRecord{{0, 1.0, false}}, records, input)
+	}
+}
+
+func TestSearchStreamHandlerError(t *testing.T) {
+	assert := assert.New(t)
+	stop := errors.New("stop")
+	calls := 0
+	err := MustCompile("@").SearchStream(strings.NewReader("1\n2\n3\n"), func(record int, result interface{}, err error) error {
+		calls++
+		if record == 1 {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(stop, err)
+	assert.Equal(2, calls)
+}
+
+type failingReader struct {
+	data string
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, io.ErrClosedPipe
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestStream(t *testing.T) {
+	assert := assert.New(t)
+	stream := MustCompile("a").Stream(&failingReader{data: "{\"a\": 1}\n{\"a\": 2}\n"})
+	var results []interface{}
+	for stream.Next() {
+		result, err := stream.Result()
+		assert.Nil(err)
+		assert.Equal(len(results), stream.Record())
+		results = append(results, result)
+	}
+	assert.Equal([]interface{}{1.0, 2.0}, results)
+	assert.Equal(io.ErrClosedPipe, stream.Err())
+	assert.False(stream.Next())
+}
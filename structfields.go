@@ -0,0 +1,219 @@
+package jmespath
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// structField is a field of a struct, or of a struct embedded in it, that
+// can be looked up by name.
+type structField struct {
+	name string
+	// tagged is set when the name comes from a json tag.
+	tagged bool
+	// index is the sequence of field indexes leading to the field, as for
+	// reflect.Value.FieldByIndex, except that embedded pointers are
+	// followed.
+	index []int
+	typ   reflect.Type
+}
+
+// structFields are the fields of a struct type, by name.
+type structFields struct {
+	list   []structField
+	byName map[string]int
+}
+
+// structFieldCache maps struct types to their *structFields.
+var structFieldCache sync.Map
+
+// cachedStructFields returns the fields of t, which must be a struct type.
+func cachedStructFields(t reflect.Type) *structFields {
+	if fields, ok := structFieldCache.Load(t); ok {
+		return fields.(*structFields)
+	}
+	list := typeFields(t)
+	fields := &structFields{list: list, byName: make(map[string]int, len(list))}
+	for i, field := range list {
+		fields.byName[field.name] = i
+	}
+	cached, _ := structFieldCache.LoadOrStore(t, fields)
+	return cached.(*structFields)
+}
+
+// lookup returns the field named key, preferring an exact match but also
+// accepting a case-insensitive match, like encoding/json does.
+func (fields *structFields) lookup(key string) *structField {
+	if i, ok := fields.byName[key]; ok {
+		return &fields.list[i]
+	}
+	for i := range fields.list {
+		if strings.EqualFold(fields.list[i].name, key) {
+			return &fields.list[i]
+		}
+	}
+	return nil
+}
+
+// value returns the value of the field in v, or false if the field is in
+// an embedded struct that v points to with a nil pointer.
+func (field *structField) value(v reflect.Value) (reflect.Value, bool) {
+	for _, i := range field.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// typeFields returns the fields of t that encoding/json would encode: the
+// exported fields, named by their json tag if they have one, and the
+// fields of embedded structs that are not hidden by other fields.
+func typeFields(t reflect.Type) []structField {
+	// The embedded structs are visited in breadth-first order, so that
+	// the fields closer to t are found first.
+	var current []structField
+	next := []structField{{typ: t}}
+	var count, nextCount map[reflect.Type]int
+	visited := map[reflect.Type]bool{}
+	var fields []structField
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				exported := sf.PkgPath == ""
+				if sf.Anonymous {
+					embedded := sf.Type
+					if embedded.Kind() == reflect.Ptr {
+						embedded = embedded.Elem()
+					}
+					// The exported fields of unexported embedded
+					// structs are still promoted.
+					if !exported && embedded.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !exported {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name := tag
+				if comma := strings.Index(tag, ","); comma >= 0 {
+					name = tag[:comma]
+				}
+				if !isValidTag(name) {
+					name = ""
+				}
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					field := structField{name: name, tagged: name != "", index: index, typ: ft}
+					if field.name == "" {
+						field.name = sf.Name
+					}
+					fields = append(fields, field)
+					if count[f.typ] > 1 {
+						// The struct is embedded more than once at
+						// this depth, the field is ambiguous.
+						fields = append(fields, field)
+					}
+					continue
+				}
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, structField{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x, y := fields[i], fields[j]
+		if x.name != y.name {
+			return x.name < y.name
+		}
+		if len(x.index) != len(y.index) {
+			return len(x.index) < len(y.index)
+		}
+		if x.tagged != y.tagged {
+			return x.tagged
+		}
+		return lessIndex(x.index, y.index)
+	})
+
+	// Of the fields with the same name, only the one closest to t is
+	// kept, or the tagged one if there are several. Fields that are
+	// still ambiguous are dropped.
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		name := fields[i].name
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != name {
+				break
+			}
+		}
+		same := fields[i : i+advance]
+		if len(same) > 1 && len(same[0].index) == len(same[1].index) && same[0].tagged == same[1].tagged {
+			continue
+		}
+		out = append(out, same[0])
+	}
+
+	// The fields are kept in the order encoding/json encodes them, which
+	// is also the order they are tried in case-insensitive lookups.
+	sort.Slice(out, func(i, j int) bool {
+		return lessIndex(out[i].index, out[j].index)
+	})
+	return out
+}
+
+func lessIndex(x, y []int) bool {
+	for k, xk := range x {
+		if k >= len(y) {
+			return false
+		}
+		if xk != y[k] {
+			return xk < y[k]
+		}
+	}
+	return len(x) < len(y)
+}
+
+// isValidTag reports whether name can be used as a field name in a json
+// tag.
+func isValidTag(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// Backslashes and quotes are reserved, other
+			// punctuation is allowed.
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
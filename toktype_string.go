@@ -38,12 +38,21 @@ func _() {
 	_ = x[tExpref-27]
 	_ = x[tAnd-28]
 	_ = x[tNot-29]
-	_ = x[tEOF-30]
+	_ = x[tVariable-30]
+	_ = x[tAssign-31]
+	_ = x[tPlus-32]
+	_ = x[tMinus-33]
+	_ = x[tDivide-34]
+	_ = x[tModulo-35]
+	_ = x[tIntegerDivide-36]
+	_ = x[tRoot-37]
+	_ = x[tQuestion-38]
+	_ = x[tEOF-39]
 }
 
-const _tokType_name = "tUnknowntStartDottFiltertFlattentLparentRparentLbrackettRbrackettLbracetRbracetOrtPipetNumbertUnquotedIdentifiertQuotedIdentifiertCommatColontLTtLTEtGTtGTEtEQtNEtJSONLiteraltStringLiteraltCurrenttExpreftAndtNottEOF"
+const _tokType_name = "tUnknowntStartDottFiltertFlattentLparentRparentLbrackettRbrackettLbracetRbracetOrtPipetNumbertUnquotedIdentifiertQuotedIdentifiertCommatColontLTtLTEtGTtGTEtEQtNEtJSONLiteraltStringLiteraltCurrenttExpreftAndtNottVariabletAssigntPlustMinustDividetModulotIntegerDividetRoottQuestiontEOF"
 
-var _tokType_index = [...]uint8{0, 8, 13, 17, 24, 32, 39, 46, 55, 64, 71, 78, 81, 86, 93, 112, 129, 135, 141, 144, 148, 151, 155, 158, 161, 173, 187, 195, 202, 206, 210, 214}
+var _tokType_index = [...]uint16{0, 8, 13, 17, 24, 32, 39, 46, 55, 64, 71, 78, 81, 86, 93, 112, 129, 135, 141, 144, 148, 151, 155, 158, 161, 173, 187, 195, 202, 206, 210, 219, 226, 231, 237, 244, 251, 265, 270, 279, 283}
 
 func (i tokType) String() string {
 	if i < 0 || i >= tokType(len(_tokType_index)-1) {
@@ -0,0 +1,240 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// TraceStep records the evaluation of an AST node by Trace. The steps form
+// a tree: the steps of the nodes evaluated to produce the output of a node
+// are its children, in the order they were evaluated. A TraceStep encodes
+// to JSON with the keys of the field tags.
+type TraceStep struct {
+	// Node is the type of the AST node, such as "ASTField".
+	Node string `json:"node"`
+	// Expression is the node written as a JMESPath expression.
+	Expression string `json:"expression"`
+	// Input is a summary of the value the node was evaluated against.
+	Input string `json:"input"`
+	// Output is the value the node evaluated to, nil if it failed.
+	Output interface{} `json:"output"`
+	// Error is the message of the error the evaluation of the node
+	// failed with, empty if it succeeded.
+	Error    string       `json:"error,omitempty"`
+	Children []*TraceStep `json:"children,omitempty"`
+}
+
+// String returns the tree of steps as text, one step per line, indented by
+// its depth in the tree. Each line holds the expression of the node, its
+// type, and summaries of its input and of its output or error.
+func (s *TraceStep) String() string {
+	var b strings.Builder
+	s.writeText(&b, 0)
+	return b.String()
+}
+
+func (s *TraceStep) writeText(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(b, "%s (%s) %s -> ", s.Expression, strings.TrimPrefix(s.Node, "AST"), s.Input)
+	if s.Error != "" {
+		b.WriteString("error: " + s.Error)
+	} else {
+		b.WriteString(summarize(s.Output))
+	}
+	b.WriteByte('\n')
+	for _, child := range s.Children {
+		child.writeText(b, depth+1)
+	}
+}
+
+// Trace evaluates the expression against data like Search, and records
+// the evaluation of each AST node along the way. The trace is returned
+// even when the evaluation fails, its steps lead to the node that failed.
+// The expression is traced as it was written, before it was optimized, so
+// that each of its parts has its own step.
+//
+// Tracing is meant for finding out why an expression doesn't return the
+// expected result; it is much slower than Search, and its output may
+// change between releases.
+func (jp *JMESPath) Trace(data interface{}) (interface{}, *TraceStep, error) {
+	intr := jp.newEvaluation()
+	intr.root = data
+	intr.tracer = &tracer{}
+	result, err := intr.Execute(jp.parsed, data)
+	if err == nil {
+		err = intr.checkResult(result)
+	}
+	if err != nil {
+		return nil, intr.tracer.root, err
+	}
+	return result, intr.tracer.root, nil
+}
+
+// Trace evaluates a JMESPath expression against input data and records
+// the evaluation of each AST node, as described for JMESPath.Trace.
+func Trace(expression string, data interface{}) (interface{}, *TraceStep, error) {
+	jp, err := defaultCache.Compile(expression)
+	if err != nil {
+		return nil, nil, err
+	}
+	return jp.Trace(data)
+}
+
+// tracer builds the tree of steps of a traced evaluation.
+type tracer struct {
+	root *TraceStep
+	// current is the step of the node being evaluated.
+	current *TraceStep
+}
+
+// traceExecute evaluates node like Execute, recording the evaluation as a
+// step of the node being evaluated.
+func (intr *treeInterpreter) traceExecute(node ASTNode, value interface{}) (interface{}, error) {
+	step := &TraceStep{
+		Node:       node.NodeType.String(),
+		Expression: node.Expression(),
+		Input:      summarize(value),
+	}
+	parent := intr.tracer.current
+	if parent == nil {
+		intr.tracer.root = step
+	} else {
+		parent.Children = append(parent.Children, step)
+	}
+	intr.tracer.current = step
+	result, err := intr.executeLimited(node, value)
+	intr.tracer.current = parent
+	if err != nil {
+		step.Error = err.Error()
+		return nil, err
+	}
+	if _, ok := result.(expRef); !ok {
+		// Expression references aren't values, their expression is
+		// already that of the step.
+		step.Output = result
+	}
+	return result, nil
+}
+
+// maxSummaryLength is the number of characters after which summaries are
+// cut.
+const maxSummaryLength = 60
+
+// summarize returns the value as JSON, cut to maxSummaryLength characters.
+// The value is only encoded up to the cut, so that summarizing the large
+// values a search goes through stays cheap.
+func summarize(value interface{}) string {
+	var summary summaryWriter
+	err := summary.encode(value)
+	text := summary.text.String()
+	if err != nil && err != errSummaryFull {
+		text = fmt.Sprintf("%v", value)
+	}
+	runes := []rune(text)
+	if len(runes) > maxSummaryLength {
+		return string(runes[:maxSummaryLength]) + "..."
+	}
+	return text
+}
+
+// errSummaryFull stops the encoding of a summary that is long enough.
+var errSummaryFull = errors.New("summary is full")
+
+// summaryWriter encodes values to JSON like encoding/json, arrays and
+// objects one element at a time, until it has written more than
+// maxSummaryLength characters.
+type summaryWriter struct {
+	text   strings.Builder
+	length int
+}
+
+func (w *summaryWriter) write(text string) error {
+	w.text.WriteString(text)
+	w.length += utf8.RuneCountInString(text)
+	if w.length > maxSummaryLength {
+		return errSummaryFull
+	}
+	return nil
+}
+
+func (w *summaryWriter) encode(value interface{}) error {
+	if _, ok := value.(json.Marshaler); !ok && value != nil {
+		rv := reflect.ValueOf(value)
+		for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			// Byte slices are encoded as base64 strings.
+			if rv.Type().Elem().Kind() != reflect.Uint8 && !(rv.Kind() == reflect.Slice && rv.IsNil()) {
+				return w.encodeArray(rv)
+			}
+		case reflect.Map, reflect.Struct:
+			if _, ok := rv.Interface().(json.Marshaler); ok {
+				break
+			}
+			if members, ok := objectMembers(rv.Interface()); ok {
+				return w.encodeObject(members)
+			}
+		}
+	}
+	// Only the start of a long string can be part of the summary.
+	if s, ok := value.(string); ok && len(s) > utf8.UTFMax*maxSummaryLength {
+		value = s[:utf8.UTFMax*maxSummaryLength]
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return w.write(string(encoded))
+}
+
+func (w *summaryWriter) encodeArray(rv reflect.Value) error {
+	if err := w.write("["); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if i > 0 {
+			if err := w.write(","); err != nil {
+				return err
+			}
+		}
+		if err := w.encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return w.write("]")
+}
+
+func (w *summaryWriter) encodeObject(members map[string]interface{}) error {
+	if err := w.write("{"); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(members))
+	for key := range members {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i > 0 {
+			if err := w.write(","); err != nil {
+				return err
+			}
+		}
+		if err := w.encode(key); err != nil {
+			return err
+		}
+		if err := w.write(":"); err != nil {
+			return err
+		}
+		if err := w.encode(members[key]); err != nil {
+			return err
+		}
+	}
+	return w.write("}")
+}
@@ -0,0 +1,165 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	assert := assert.New(t)
+	data := decodeJSON(t, `{"people": [{"name": "a", "age": 17}, {"name": "b", "age": 42}]}`)
+	result, trace, err := Trace("people[?age > `20`].name", data)
+	assert.Nil(err)
+	assert.Equal([]interface{}{"b"}, result)
+	expected := strings.Join([]string{
+		"people[?age > `20`].name (FilterProjection) " + summarize(data) + ` -> ["b"]`,
+		`  people (Field) ` + summarize(data) + ` -> [{"age":17,"name":"a"},{"age":42,"name":"b"}]`,
+		"  age > `20` (Comparator) " + `{"age":17,"name":"a"} -> false`,
+		`    age (Field) {"age":17,"name":"a"} -> 17`,
+		"    `20` (Literal) " + `{"age":17,"name":"a"} -> 20`,
+		"  age > `20` (Comparator) " + `{"age":42,"name":"b"} -> true`,
+		`    age (Field) {"age":42,"name":"b"} -> 42`,
+		"    `20` (Literal) " + `{"age":42,"name":"b"} -> 20`,
+		`  name (Field) {"age":42,"name":"b"} -> "b"`,
+		"",
+	}, "\n")
+	assert.Equal(expected, trace.String())
+
+	encoded, err := json.Marshal(trace.Children[1])
+	assert.Nil(err)
+	assert.JSONEq(`{
+		"node": "ASTComparator",
+		"expression": "age > `+"`20`"+`",
+		"input": "{\"age\":17,\"name\":\"a\"}",
+		"output": false,
+		"children": [
+			{"node": "ASTField", "expression": "age", "input": "{\"age\":17,\"name\":\"a\"}", "output": 17},
+			{"node": "ASTLiteral", "expression": "`+"`20`"+`", "input": "{\"age\":17,\"name\":\"a\"}", "output": 20}
+		]
+	}`, string(encoded))
+}
+
+func TestTraceUnoptimized(t *testing.T) {
+	assert := assert.New(t)
+	result, trace, err := Trace("`1` + `2` | @", nil)
+	assert.Nil(err)
+	assert.Equal(3.0, result)
+	assert.Equal("`1` + `2` | @", trace.Expression)
+	if assert.Len(trace.Children, 2) {
+		assert.Equal("`1` + `2`", trace.Children[0].Expression)
+		assert.Len(trace.Children[0].Children, 2)
+	}
+}
+
+func TestTraceErrors(t *testing.T) {
+	assert := assert.New(t)
+	data := decodeJSON(t, `{"foo": {"bar": "baz"}}`)
+	result, trace, err := Trace("length(foo) > abs(foo.bar)", data)
+	assert.NotNil(err)
+	assert.Nil(result)
+	if assert.NotNil(trace) {
+		// The steps lead to the failing node.
+		assert.Equal(err.Error(), trace.Error)
+		failed := trace.Children[1]
+		assert.Equal("abs(foo.bar)", failed.Expression)
+		assert.Equal(err.Error(), failed.Error)
+		assert.Nil(failed.Output)
+		assert.Equal("", failed.Children[0].Error)
+		assert.Equal("baz", failed.Children[0].Output)
+		assert.Contains(trace.String(), "abs(foo.bar) (FunctionExpression) "+summarize(data)+" -> error: ")
+	}
+
+	_, trace, err = MustCompile("foo.bar").WithLimits(Limits{MaxSteps: 2}).Trace(data)
+	assert.NotNil(err)
+	assert.Len(trace.Children, 2)
+
+	_, trace, err = Trace("foo[", data)
+	assert.NotNil(err)
+	assert.Nil(trace)
+}
+
+func TestSummarize(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("null", summarize(nil))
+	assert.Equal(`{"a":[1,2]}`, summarize(map[string]interface{}{"a": []interface{}{1, 2}}))
+	long := strings.Repeat("é", 100)
+	assert.Equal(`"`+strings.Repeat("é", maxSummaryLength-1)+"...", summarize(long))
+	assert.Equal("1", summarize(json.Number("1")))
+
+	// Go values are summarized as encoding/json encodes them.
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	for _, value := range []interface{}{
+		[]int{1, 2},
+		map[string]int{"b": 1, "a": 2},
+		&point{X: 1},
+		[]*point{{X: 1, Y: 2}, nil},
+		[]byte("abc"),
+		json.RawMessage(`{"a": 1}`),
+		[]string(nil),
+		"<a & b>",
+	} {
+		encoded, err := json.Marshal(value)
+		assert.Nil(err)
+		assert.Equal(string(encoded), summarize(value), "%#v", value)
+	}
+
+	// Values are only encoded up to the cut.
+	cut := []interface{}{strings.Repeat("a", 100), math.NaN()}
+	assert.Equal(`["`+strings.Repeat("a", maxSummaryLength-2)+"...", summarize(cut))
+	assert.Equal("[NaN]", summarize([]interface{}{math.NaN()}))
+	large := make([]interface{}, 1000000)
+	assert.Equal("["+strings.Repeat("null,", 11)+"null...", summarize(large))
+}
+
+// TestTraceCompliance checks that Trace returns the result of Search, and
+// that the trace of the whole expression has that result as output.
+func TestTraceCompliance(t *testing.T) {
+	assert := assert.New(t)
+	files, err := filepath.Glob("compliance/*.json")
+	assert.Nil(err)
+	for _, filename := range files {
+		data, err := ioutil.ReadFile(filename)
+		if !assert.Nil(err) {
+			continue
+		}
+		var suites []TestSuite
+		if !assert.Nil(json.Unmarshal(data, &suites), filename) {
+			continue
+		}
+		for _, suite := range suites {
+			document, err := json.Marshal(suite.Given)
+			if !assert.Nil(err, filename) {
+				continue
+			}
+			for _, testcase := range suite.TestCases {
+				compiled, err := Compile(testcase.Expression)
+				if err != nil {
+					continue
+				}
+				// sort_by sorts its input in place, each search gets
+				// its own copy of the document.
+				var given interface{}
+				assert.Nil(json.Unmarshal(document, &given))
+				expected, expectedErr := compiled.Search(given)
+				assert.Nil(json.Unmarshal(document, &given))
+				actual, trace, actualErr := compiled.Trace(given)
+				assert.Equal(expectedErr, actualErr, "%s: %s", filename, testcase.Expression)
+				if !sameValues(expected, actual) {
+					assert.Equal(expected, actual, "%s: %s", filename, testcase.Expression)
+				}
+				if actualErr == nil && assert.NotNil(trace) {
+					assert.Equal(actual, trace.Output, "%s: %s", filename, testcase.Expression)
+				}
+			}
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package jmespath
 
 import (
-	"errors"
 	"reflect"
 )
 
@@ -46,11 +45,64 @@ func isFalse(value interface{}) bool {
 
 // ObjsEqual is a generic object equality check.
 // It will take two arbitrary objects and recursively determine
-// if they are equal.
+// if they are equal. Numbers are equal if they have the same value,
+// whatever their types, and arrays and objects are compared element by
+// element and member by member, whatever the Go types holding them.
 func objsEqual(left interface{}, right interface{}) bool {
+	if order, ok := compareNumbers(left, right); ok {
+		return order == 0
+	}
+	if l, ok := objectMembers(left); ok {
+		r, ok := objectMembers(right)
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for key, value := range l {
+			other, ok := r[key]
+			if !ok || !objsEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	if isSliceType(left) {
+		l, r := projectionItems(left), projectionItems(right)
+		if r == nil || len(l) != len(r) {
+			return false
+		}
+		for i := range l {
+			if !objsEqual(l[i], r[i]) {
+				return false
+			}
+		}
+		return true
+	}
 	return reflect.DeepEqual(left, right)
 }
 
+// objectMembers returns the members of an object: a map with string keys,
+// or a struct whose members are its fields as encoding/json encodes them,
+// or a pointer to either. If the value isn't an object, then nil is
+// returned along with a second value of false.
+func objectMembers(v interface{}) (map[string]interface{}, bool) {
+	if m, ok := toObject(v); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, false
+	}
+	m := make(map[string]interface{})
+	forEachMember(rv, func(name string, value interface{}) error {
+		m[name] = value
+		return nil
+	})
+	return m, true
+}
+
 // SliceParam refers to a single part of a slice.
 // A slice consists of a start, a stop, and a step, similar to
 // python slices.
@@ -84,7 +136,7 @@ func computeSliceParams(length int, parts []sliceParam) ([]int, error) {
 	if !parts[2].Specified {
 		step = 1
 	} else if parts[2].N == 0 {
-		return nil, errors.New("Invalid slice, step cannot be 0")
+		return nil, &InvalidValueError{Function: "slice", ArgIndex: 2, Reason: "step cannot be 0"}
 	} else {
 		step = parts[2].N
 	}
@@ -138,43 +190,44 @@ func capSlice(length int, actual int, step int) int {
 }
 
 // ToArrayNum converts an empty interface type to a slice of float64.
-// If any element in the array cannot be converted, then nil is returned
-// along with a second value of false.
+// The value may be a slice of any type. If any element in the array
+// cannot be converted, then nil is returned along with a second value of
+// false.
 func toArrayNum(data interface{}) ([]float64, bool) {
-	// Is there a better way to do this with reflect?
-	if d, ok := data.([]interface{}); ok {
-		result := make([]float64, len(d))
-		for i, el := range d {
-			item, ok := el.(float64)
-			if !ok {
-				return nil, false
-			}
-			result[i] = item
+	d := projectionItems(data)
+	if d == nil {
+		return nil, false
+	}
+	result := make([]float64, len(d))
+	for i, el := range d {
+		item, ok := toFloat(el)
+		if !ok {
+			return nil, false
 		}
-		return result, true
+		result[i] = item
 	}
-	return nil, false
+	return result, true
 }
 
 // ToArrayStr converts an empty interface type to a slice of strings.
-// If any element in the array cannot be converted, then nil is returned
-// along with a second value of false.  If the input data could be entirely
-// converted, then the converted data, along with a second value of true,
-// will be returned.
+// The value may be a slice of any type. If any element in the array
+// cannot be converted, then nil is returned along with a second value of
+// false.  If the input data could be entirely converted, then the
+// converted data, along with a second value of true, will be returned.
 func toArrayStr(data interface{}) ([]string, bool) {
-	// Is there a better way to do this with reflect?
-	if d, ok := data.([]interface{}); ok {
-		result := make([]string, len(d))
-		for i, el := range d {
-			item, ok := el.(string)
-			if !ok {
-				return nil, false
-			}
-			result[i] = item
+	d := projectionItems(data)
+	if d == nil {
+		return nil, false
+	}
+	result := make([]string, len(d))
+	for i, el := range d {
+		item, ok := el.(string)
+		if !ok {
+			return nil, false
 		}
-		return result, true
+		result[i] = item
 	}
-	return nil, false
+	return result, true
 }
 
 func isSliceType(v interface{}) bool {
@@ -183,3 +236,51 @@ func isSliceType(v interface{}) bool {
 	}
 	return reflect.TypeOf(v).Kind() == reflect.Slice
 }
+
+// isMapType determines if a value is a map with string keys, or a pointer
+// to one, which is an object in JMESPath.
+func isMapType(v interface{}) bool {
+	if _, ok := v.(map[string]interface{}); ok {
+		return true
+	}
+	_, ok := mapValue(v)
+	return ok
+}
+
+// mapValue returns the map with string keys that v is or points to. If
+// there is no such map, then a second value of false is returned.
+func mapValue(v interface{}) (reflect.Value, bool) {
+	if v == nil {
+		return reflect.Value{}, false
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return reflect.Value{}, false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return reflect.Value{}, false
+	}
+	return rv, true
+}
+
+// toObject converts a map with string keys, or a pointer to one, to a
+// map[string]interface{}. If the value isn't such a map, then nil is
+// returned along with a second value of false.
+func toObject(v interface{}) (map[string]interface{}, bool) {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m, true
+	}
+	rv, ok := mapValue(v)
+	if !ok {
+		return nil, false
+	}
+	m := make(map[string]interface{}, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		m[iter.Key().String()] = iter.Value().Interface()
+	}
+	return m, true
+}
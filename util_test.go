@@ -1,11 +1,20 @@
 package jmespath
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/jmespath/go-jmespath/internal/testify/assert"
 )
 
+// decodeJSON decodes a JSON document the way users of the package do, into
+// the generic Go types.
+func decodeJSON(t *testing.T, document string) interface{} {
+	var data interface{}
+	assert.Nil(t, json.Unmarshal([]byte(document), &data), document)
+	return data
+}
+
 func TestSlicePositiveStep(t *testing.T) {
 	assert := assert.New(t)
 	input := make([]interface{}, 5)
@@ -61,6 +70,34 @@ func TestIsFalseWithMapOfUserStructs(t *testing.T) {
 	assert.True(isFalse(m))
 }
 
+func TestIsFalseWithMapTypes(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(isFalse(map[string]string{}))
+	assert.False(isFalse(map[string]string{"a": "b"}))
+	var nilMap map[string]int
+	assert.True(isFalse(nilMap))
+}
+
+func TestToObject(t *testing.T) {
+	assert := assert.New(t)
+	type named map[string]int
+	object, ok := toObject(named{"a": 1})
+	assert.True(ok)
+	assert.Equal(map[string]interface{}{"a": 1}, object)
+	_, ok = toObject(map[int]int{1: 1})
+	assert.False(ok)
+	_, ok = toObject(nil)
+	assert.False(ok)
+	object, ok = toObject(&named{"b": 2})
+	assert.True(ok)
+	assert.Equal(map[string]interface{}{"b": 2}, object)
+	_, ok = toObject((*named)(nil))
+	assert.False(ok)
+	assert.True(isMapType(map[string]interface{}{}))
+	assert.False(isMapType([]string{}))
+	assert.True(isMapType(&named{}))
+}
+
 func TestObjsEqual(t *testing.T) {
 	assert := assert.New(t)
 	assert.True(objsEqual("foo", "foo"))
@@ -70,4 +107,19 @@ func TestObjsEqual(t *testing.T) {
 	assert.True(!objsEqual(nil, "foo"))
 	assert.True(objsEqual([]int{}, []int{}))
 	assert.True(!objsEqual([]int{}, nil))
+	assert.True(objsEqual([]int{1, 2}, []interface{}{1.0, 2.0}))
+	assert.True(!objsEqual([]int{1, 2}, []interface{}{1.0}))
+	type named map[string]string
+	object := map[string]interface{}{"a": "b"}
+	assert.True(objsEqual(named{"a": "b"}, object))
+	assert.True(objsEqual(object, &named{"a": "b"}))
+	assert.True(!objsEqual(named{"a": "c"}, object))
+	assert.True(!objsEqual(named{"a": "b", "c": "d"}, object))
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y,omitempty"`
+	}
+	assert.True(objsEqual(point{1, 2}, map[string]interface{}{"x": 1.0, "y": 2.0}))
+	assert.True(objsEqual(&point{1, 2}, point{1, 2}))
+	assert.True(!objsEqual(point{1, 2}, map[string]interface{}{"x": 1.0}))
 }
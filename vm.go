@@ -0,0 +1,555 @@
+package jmespath
+
+import (
+	"errors"
+	"reflect"
+)
+
+/* This is a bytecode virtual machine. Compile lowers the AST into a flat
+   sequence of instructions which operate on a stack of values. Every
+   expression is compiled so that it replaces the value on the top of
+   the stack, the current node, with its result. The subexpression "a.b"
+   is therefore simply the code of "a" followed by the code of "b".
+   Operators that evaluate more than one expression against the current
+   node duplicate it first. Projections keep the elements still to be
+   projected in frames on a separate stack, and functions are called with
+   the tree interpreter, which also evaluates the expressions referenced
+   by exprefs.
+*/
+
+type opcode uint8
+
+const (
+	opDup opcode = iota
+	opSwap
+	opPop
+	opField
+	opFields
+	opIndex
+	opSlice
+	opLiteral
+	opRoot
+	opVariable
+	opFlatten
+	opCompare
+	opCompareLiteral
+	opArithmetic
+	opUnary
+	opNot
+	opJump
+	opJumpIfFalse
+	opJumpIfNil
+	opOr
+	opAnd
+	opMakeList
+	opMakeMap
+	opCall
+	opExpRef
+	opProject
+	opValueProject
+	opCollect
+	opSkip
+	opFirst
+	opBind
+	opUnbind
+	opEnter
+	opLeave
+	opFail
+)
+
+type instruction struct {
+	op opcode
+	// operand is the target of jumps, the number of values used by
+	// opMakeList and opCall, or the index of opIndex.
+	operand int
+	// value holds the other arguments of an instruction, e.g. the name
+	// of a field or the value of a literal.
+	value interface{}
+}
+
+// program is an expression compiled for the virtual machine.
+type program struct {
+	code []instruction
+}
+
+// literalComparison is the value of opCompareLiteral.
+type literalComparison struct {
+	operator tokType
+	literal  interface{}
+}
+
+type compiler struct {
+	code []instruction
+	// limited is set when the program is run with limits. The code of
+	// every node is then surrounded by opEnter and opLeave, so that the
+	// limits are enforced just like the tree interpreter does. Nodes are
+	// then also never combined into a single instruction.
+	limited bool
+}
+
+// compile lowers the AST into a program. Programs compiled with limited
+// set account for the evaluation of every node, and have to be run by an
+// interpreter with limits.
+func compile(ast ASTNode, limited bool) *program {
+	c := &compiler{limited: limited}
+	c.compile(ast)
+	return &program{code: c.code}
+}
+
+func (c *compiler) emit(op opcode, operand int, value interface{}) int {
+	c.code = append(c.code, instruction{op: op, operand: operand, value: value})
+	return len(c.code) - 1
+}
+
+// patch makes the jump at the given position go to the next instruction.
+func (c *compiler) patch(jump int) {
+	c.code[jump].operand = len(c.code)
+}
+
+func (c *compiler) compile(node ASTNode) {
+	if c.limited {
+		c.emit(opEnter, 0, nil)
+	}
+	c.compileNode(node)
+	if c.limited {
+		c.emit(opLeave, 0, node.NodeType)
+	}
+}
+
+// compileOperand compiles node so that its result is pushed on top of the
+// current node instead of replacing it.
+func (c *compiler) compileOperand(node ASTNode) {
+	c.emit(opDup, 0, nil)
+	c.compile(node)
+}
+
+func (c *compiler) compileNode(node ASTNode) {
+	switch node.NodeType {
+	case ASTIdentity, ASTCurrentNode:
+	case ASTField:
+		c.emit(opField, 0, node.Value)
+	case ASTLiteral:
+		c.emit(opLiteral, 0, node.Value)
+	case ASTRootNode:
+		c.emit(opRoot, 0, nil)
+	case ASTVariable:
+		c.emit(opVariable, 0, node.Value)
+	case ASTIndex:
+		c.emit(opIndex, node.Value.(int), nil)
+	case ASTSlice:
+		c.emit(opSlice, 0, node.Value)
+	case ASTSubexpression, ASTIndexExpression, ASTPipe:
+		if names := fieldPath(node); names != nil && !c.limited {
+			c.emit(opFields, 0, names)
+			break
+		}
+		for _, child := range node.Children {
+			c.compile(child)
+		}
+	case ASTKeyValPair:
+		c.compile(node.Children[0])
+	case ASTComparator, ASTArithmeticExpression:
+		if node.NodeType == ASTComparator && node.Children[1].NodeType == ASTLiteral && !c.limited {
+			// The literal doesn't depend on the current node, which can
+			// be replaced by the left hand side right away.
+			c.compile(node.Children[0])
+			c.emit(opCompareLiteral, 0, literalComparison{node.Value.(tokType), node.Children[1].Value})
+			break
+		}
+		c.compileOperand(node.Children[0])
+		c.emit(opSwap, 0, nil)
+		c.compile(node.Children[1])
+		if node.NodeType == ASTComparator {
+			c.emit(opCompare, 0, node.Value)
+		} else {
+			c.emit(opArithmetic, 0, node.Value)
+		}
+	case ASTArithmeticUnaryExpression:
+		c.compile(node.Children[0])
+		c.emit(opUnary, 0, node.Value)
+	case ASTNotExpression:
+		c.compile(node.Children[0])
+		c.emit(opNot, 0, nil)
+	case ASTOrExpression, ASTAndExpression:
+		c.compileOperand(node.Children[0])
+		op := opOr
+		if node.NodeType == ASTAndExpression {
+			op = opAnd
+		}
+		end := c.emit(op, 0, nil)
+		c.compile(node.Children[1])
+		c.patch(end)
+	case ASTTernaryExpression:
+		c.compileOperand(node.Children[0])
+		otherwise := c.emit(opJumpIfFalse, 0, nil)
+		c.compile(node.Children[1])
+		end := c.emit(opJump, 0, nil)
+		c.patch(otherwise)
+		c.compile(node.Children[2])
+		c.patch(end)
+	case ASTMultiSelectList, ASTMultiSelectHash:
+		end := c.emit(opJumpIfNil, 0, nil)
+		keys := make([]string, len(node.Children))
+		for i, child := range node.Children {
+			c.compileOperand(child)
+			c.emit(opSwap, 0, nil)
+			if node.NodeType == ASTMultiSelectHash {
+				keys[i] = child.Value.(string)
+			}
+		}
+		c.emit(opPop, 0, nil)
+		if node.NodeType == ASTMultiSelectHash {
+			c.emit(opMakeMap, 0, keys)
+		} else {
+			c.emit(opMakeList, len(node.Children), nil)
+		}
+		c.patch(end)
+	case ASTFunctionExpression:
+		for _, arg := range node.Children {
+			c.compileOperand(arg)
+			c.emit(opSwap, 0, nil)
+		}
+		c.emit(opPop, 0, nil)
+		c.emit(opCall, len(node.Children), node.Value)
+	case ASTExpRef:
+		c.emit(opExpRef, 0, node.Children[0])
+	case ASTFlatten:
+		c.compile(node.Children[0])
+		c.emit(opFlatten, 0, nil)
+	case ASTProjection, ASTValueProjection:
+		c.compile(node.Children[0])
+		op := opProject
+		if node.NodeType == ASTValueProjection {
+			op = opValueProject
+		}
+		start := c.emit(op, 0, nil)
+		c.compile(node.Children[1])
+		c.emit(opCollect, start+1, nil)
+		c.patch(start)
+	case ASTFilterProjection:
+		c.compile(node.Children[0])
+		start := c.emit(opProject, 0, nil)
+		c.compileOperand(node.Children[2])
+		skip := c.emit(opJumpIfFalse, 0, nil)
+		c.compile(node.Children[1])
+		c.emit(opCollect, start+1, nil)
+		end := c.emit(opJump, 0, nil)
+		c.patch(skip)
+		c.emit(opSkip, start+1, nil)
+		c.patch(end)
+		c.patch(start)
+	case ASTFirstMatch:
+		c.compile(node.Children[0])
+		start := c.emit(opProject, 0, nil)
+		c.compileOperand(node.Children[2])
+		skip := c.emit(opJumpIfFalse, 0, nil)
+		c.compile(node.Children[1])
+		c.emit(opFirst, start+1, nil)
+		found := c.emit(opJump, 0, nil)
+		c.patch(skip)
+		c.emit(opSkip, start+1, nil)
+		c.patch(start)
+		// Projections of no elements result in an empty array.
+		c.emit(opIndex, 0, nil)
+		c.patch(found)
+	case ASTLetExpression:
+		bindings := node.Children[:len(node.Children)-1]
+		names := make([]string, len(bindings))
+		for i, binding := range bindings {
+			c.compileOperand(binding.Children[0])
+			c.emit(opSwap, 0, nil)
+			names[i] = binding.Value.(string)
+		}
+		c.emit(opBind, 0, names)
+		c.compile(node.Children[len(node.Children)-1])
+		c.emit(opUnbind, 0, nil)
+	default:
+		c.emit(opFail, 0, errors.New("Unknown AST node: "+node.NodeType.String()))
+	}
+}
+
+// fieldPath returns the names of the fields of a chain of subexpressions
+// and pipes made only of fields, e.g. "a.b | c", or nil for any other node.
+func fieldPath(node ASTNode) []string {
+	switch node.NodeType {
+	case ASTField:
+		return []string{node.Value.(string)}
+	case ASTSubexpression, ASTPipe:
+		left := fieldPath(node.Children[0])
+		right := fieldPath(node.Children[1])
+		if left == nil || right == nil {
+			return nil
+		}
+		return append(left, right...)
+	}
+	return nil
+}
+
+// projection is the state of a projection being evaluated.
+type projection struct {
+	items     []interface{}
+	next      int
+	collected []interface{}
+}
+
+// vm runs programs. The tree interpreter holds the state shared with the
+// functions called by the program: the context, limits, root and scope.
+type vm struct {
+	intr *treeInterpreter
+}
+
+// initialStackSize is enough for most expressions, the stack starts out in
+// the frame of run and is only moved to the heap if it has to grow.
+const initialStackSize = 16
+
+// run evaluates the program against data.
+func (m *vm) run(prog *program, data interface{}) (interface{}, error) {
+	stack := make([]interface{}, 1, initialStackSize)
+	stack[0] = data
+	var projections []projection
+	code := prog.code
+	pc := 0
+	for pc < len(code) {
+		in := &code[pc]
+		pc++
+		top := len(stack) - 1
+		switch in.op {
+		case opDup:
+			stack = append(stack, stack[top])
+		case opSwap:
+			stack[top], stack[top-1] = stack[top-1], stack[top]
+		case opPop:
+			stack = stack[:top]
+		case opField:
+			result, err := m.intr.field(in.value.(string), stack[top])
+			if err != nil {
+				return nil, err
+			}
+			stack[top] = result
+		case opFields:
+			for _, name := range in.value.([]string) {
+				result, err := m.intr.field(name, stack[top])
+				if err != nil {
+					return nil, err
+				}
+				stack[top] = result
+			}
+		case opIndex:
+			stack[top] = index(stack[top], in.operand)
+		case opSlice:
+			result, err := m.intr.sliceOf(in.value.([]*int), stack[top])
+			if err != nil {
+				return nil, err
+			}
+			stack[top] = result
+		case opLiteral:
+			stack[top] = in.value
+		case opRoot:
+			stack[top] = m.intr.root
+		case opVariable:
+			result, err := m.intr.variable(in.value.(string))
+			if err != nil {
+				return nil, err
+			}
+			stack[top] = result
+		case opFlatten:
+			result, err := m.intr.flatten(stack[top])
+			if err != nil {
+				return nil, err
+			}
+			stack[top] = result
+		case opCompare:
+			right := stack[top]
+			stack = stack[:top]
+			stack[top-1] = compare(in.value.(tokType), stack[top-1], right)
+		case opCompareLiteral:
+			comparison := in.value.(literalComparison)
+			stack[top] = compare(comparison.operator, stack[top], comparison.literal)
+		case opArithmetic:
+			right := stack[top]
+			stack = stack[:top]
+			result, err := binaryArithmetic(in.value.(tokType), stack[top-1], right)
+			if err != nil {
+				return nil, err
+			}
+			stack[top-1] = result
+		case opUnary:
+			result, err := unaryArithmetic(in.value.(tokType), stack[top])
+			if err != nil {
+				return nil, err
+			}
+			stack[top] = result
+		case opNot:
+			stack[top] = isFalse(stack[top])
+		case opJump:
+			pc = in.operand
+		case opJumpIfFalse:
+			condition := stack[top]
+			stack = stack[:top]
+			if isFalse(condition) {
+				pc = in.operand
+			}
+		case opJumpIfNil:
+			if stack[top] == nil {
+				pc = in.operand
+			}
+		case opOr, opAnd:
+			// The result of the left hand side is on top of the
+			// current node. It is the result of the expression unless
+			// the right hand side has to be evaluated.
+			if isFalse(stack[top]) == (in.op == opAnd) {
+				stack[top-1] = stack[top]
+				pc = in.operand
+			}
+			stack = stack[:top]
+		case opMakeList:
+			collected := make([]interface{}, in.operand)
+			copy(collected, stack[len(stack)-in.operand:])
+			stack = stack[:len(stack)-in.operand]
+			stack = append(stack, collected)
+		case opMakeMap:
+			keys := in.value.([]string)
+			values := stack[len(stack)-len(keys):]
+			collected := make(map[string]interface{}, len(keys))
+			for i, key := range keys {
+				collected[key] = values[i]
+			}
+			stack = stack[:len(stack)-len(keys)]
+			stack = append(stack, collected)
+		case opCall:
+			// The arguments are copied as functions may keep them.
+			args := make([]interface{}, in.operand)
+			copy(args, stack[len(stack)-in.operand:])
+			stack = stack[:len(stack)-in.operand]
+			result, err := m.intr.fCall.CallFunction(in.value.(string), args, m.intr)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, result)
+		case opExpRef:
+			stack[top] = expRef{ref: in.value.(ASTNode), intr: m.intr, scope: m.intr.scope}
+		case opProject, opValueProject:
+			// The body of the projection follows, it ends with opCollect
+			// or opSkip which loop back to it while there are elements
+			// left to project.
+			left := stack[top]
+			stack = stack[:top]
+			var items []interface{}
+			if in.op == opProject {
+				items = projectionItems(left)
+			} else if values, ok := toObject(left); ok {
+				items = make([]interface{}, 0, len(values))
+				for _, value := range values {
+					items = append(items, value)
+				}
+			}
+			if items == nil {
+				stack = append(stack, nil)
+				pc = in.operand
+				break
+			}
+			if len(items) == 0 {
+				stack = append(stack, []interface{}{})
+				pc = in.operand
+				break
+			}
+			if err := m.intr.checkContext(); err != nil {
+				return nil, err
+			}
+			projections = append(projections, projection{items: items, next: 1})
+			stack = append(stack, items[0])
+		case opCollect, opSkip:
+			result := stack[top]
+			stack = stack[:top]
+			p := &projections[len(projections)-1]
+			if in.op == opCollect && result != nil {
+				if p.collected == nil {
+					p.collected = make([]interface{}, 0, len(p.items))
+				}
+				p.collected = append(p.collected, result)
+				if err := m.intr.checkCollectionSize(len(p.collected)); err != nil {
+					return nil, err
+				}
+			}
+			if p.next < len(p.items) {
+				if err := m.intr.checkContext(); err != nil {
+					return nil, err
+				}
+				stack = append(stack, p.items[p.next])
+				p.next++
+				pc = in.operand
+				break
+			}
+			if p.collected == nil {
+				p.collected = []interface{}{}
+			}
+			stack = append(stack, p.collected)
+			projections = projections[:len(projections)-1]
+		case opFirst:
+			// Like opCollect, but ends the projection with the first
+			// result, or nil if there is none.
+			result := stack[top]
+			stack = stack[:top]
+			p := &projections[len(projections)-1]
+			if result == nil && p.next < len(p.items) {
+				if err := m.intr.checkContext(); err != nil {
+					return nil, err
+				}
+				stack = append(stack, p.items[p.next])
+				p.next++
+				pc = in.operand
+				break
+			}
+			stack = append(stack, result)
+			projections = projections[:len(projections)-1]
+		case opBind:
+			// The values of the variables are below the current node.
+			names := in.value.([]string)
+			values := stack[top-len(names) : top]
+			inner := &scope{
+				variables: make(map[string]interface{}, len(names)),
+				parent:    m.intr.scope,
+			}
+			for i, name := range names {
+				inner.variables[name] = values[i]
+			}
+			stack[top-len(names)] = stack[top]
+			stack = stack[:top-len(names)+1]
+			m.intr.scope = inner
+		case opUnbind:
+			m.intr.scope = m.intr.scope.parent
+		case opEnter:
+			if err := m.intr.enter(); err != nil {
+				return nil, err
+			}
+		case opLeave:
+			m.intr.depth--
+			if err := m.intr.checkCollection(in.value.(ASTNodeType), stack[top]); err != nil {
+				return nil, err
+			}
+		case opFail:
+			return nil, in.value.(error)
+		}
+	}
+	return stack[0], nil
+}
+
+// projectionItems returns the elements of a slice to project, or nil if
+// value is not a slice.
+func projectionItems(value interface{}) []interface{} {
+	if items, ok := value.([]interface{}); ok {
+		if items == nil {
+			return []interface{}{}
+		}
+		return items
+	}
+	if !isSliceType(value) {
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+	return items
+}
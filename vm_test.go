@@ -0,0 +1,123 @@
+package jmespath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+var vmTestExpressions = []string{
+	"foo.bar",
+	"foo.list[1]",
+	"foo.list[-1:0:-1]",
+	"foo.list[*].name",
+	"foo.list[?age > `20`].name | [0]",
+	"foo.list[?age > `20`].missing | [0]",
+	"foo.list[?age > `99`] | [0]",
+	"foo.missing[?age] | [0]",
+	"foo.structs[?Age > `30`] | [0].Name",
+	"foo.list[].tags[]",
+	"foo.*.name",
+	"foo.list[*].[name, age]",
+	"foo.list[*].{n: name, a: age}",
+	"foo.missing.[a, b]",
+	"foo.list[?tags[?@ == 'x']].name",
+	"foo.list[0].name || foo.bar",
+	"foo.missing || foo.bar && foo.list[0].age",
+	"!foo.missing",
+	"foo.list[0].age > `18` ? 'adult' : 'minor'",
+	"sum(foo.list[*].age) / length(foo.list) + `1`",
+	"-foo.list[0].age % `7`",
+	"sort_by(foo.list, &age)[*].name",
+	"map(&name, foo.list)",
+	"let $min = `20` in foo.list[?age > $min].name",
+	"let $a = foo.bar in let $b = $a in [$a, $b, $.foo.bar]",
+	"foo.list[*].tags[0:1] | []",
+	"not_null(foo.missing, foo.bar)",
+	"foo.structs[?Age > `30`].Name",
+	"foo.structs[*].Tags[0]",
+	"foo.structs[]",
+}
+
+func vmTestData(t testing.TB) interface{} {
+	var data map[string]interface{}
+	err := json.Unmarshal([]byte(`{"foo": {
+		"bar": "baz",
+		"list": [
+			{"name": "a", "age": 17, "tags": ["x", "y"]},
+			{"name": "b", "age": 42, "tags": ["z"]},
+			{"name": "c", "age": 23, "tags": []}
+		]
+	}}`), &data)
+	assert.Nil(t, err)
+	data["foo"].(map[string]interface{})["structs"] = []person{
+		{Name: "d", Age: 31, Tags: []string{"t"}},
+		{Name: "e", Age: 12, Tags: []string{"u", "v"}},
+	}
+	return data
+}
+
+type person struct {
+	Name string
+	Age  float64
+	Tags []string
+}
+
+func TestVMMatchesTreeInterpreter(t *testing.T) {
+	assert := assert.New(t)
+	for _, expression := range vmTestExpressions {
+		compiled, err := Compile(expression)
+		if !assert.Nil(err, expression) {
+			continue
+		}
+		expected, err := searchWithTreeInterpreter(expression, vmTestData(t))
+		assert.Nil(err, expression)
+		actual, err := compiled.Search(vmTestData(t))
+		assert.Nil(err, expression)
+		assert.Equal(expected, actual, expression)
+	}
+}
+
+func TestVMCountsStepsLikeTreeInterpreter(t *testing.T) {
+	assert := assert.New(t)
+	limits := Limits{MaxSteps: 1 << 20, MaxDepth: 1 << 20}
+	for _, expression := range vmTestExpressions {
+		compiled := MustCompile(expression).WithLimits(limits)
+		data := vmTestData(t)
+		tree := compiled.newEvaluation()
+		tree.root = data
+		_, err := tree.Execute(compiled.ast, data)
+		assert.Nil(err, expression)
+		// sort_by sorts its argument in place, which changes the number
+		// of comparisons when the data is sorted a second time.
+		data = vmTestData(t)
+		intr := compiled.newEvaluation()
+		_, err = compiled.evaluate(intr, data)
+		assert.Nil(err, expression)
+		assert.Equal(tree.steps, intr.steps, expression)
+		assert.Equal(0, intr.depth, expression)
+	}
+}
+
+func TestVMErrors(t *testing.T) {
+	assert := assert.New(t)
+	data := vmTestData(t)
+	for _, expression := range []string{
+		"foo.bar + `1`",
+		"abs(foo.bar)",
+		"foo.list[*].[abs(name)]",
+		"$undefined",
+		"foo.list[::0]",
+	} {
+		_, err := Search(expression, data)
+		assert.NotNil(err, expression)
+	}
+}
+
+func TestVMUnknownNode(t *testing.T) {
+	assert := assert.New(t)
+	m := vm{intr: newInterpreter()}
+	_, err := m.run(compile(ASTNode{NodeType: ASTEmpty}, false), nil)
+	assert.NotNil(err)
+}
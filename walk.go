@@ -0,0 +1,40 @@
+package jmespath
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node *ASTNode) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the children of node, followed by a call of
+// w.Visit(nil).
+func Walk(v Visitor, node *ASTNode) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	for i := range node.Children {
+		Walk(v, &node.Children[i])
+	}
+	v.Visit(nil)
+}
+
+type inspector func(*ASTNode) bool
+
+func (f inspector) Visit(node *ASTNode) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the children of node, followed by a call of
+// f(nil).
+func Inspect(node *ASTNode, f func(*ASTNode) bool) {
+	Walk(inspector(f), node)
+}
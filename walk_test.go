@@ -0,0 +1,138 @@
+package jmespath
+
+import (
+	"testing"
+
+	"github.com/jmespath/go-jmespath/internal/testify/assert"
+)
+
+type countingVisitor struct {
+	counts map[ASTNodeType]int
+	ends   int
+}
+
+func (v *countingVisitor) Visit(node *ASTNode) Visitor {
+	if node == nil {
+		v.ends++
+		return nil
+	}
+	v.counts[node.NodeType]++
+	return v
+}
+
+func TestWalk(t *testing.T) {
+	assert := assert.New(t)
+	ast := MustCompile("foo.bar[?baz > `1`].length(qux)").AST()
+	v := &countingVisitor{counts: map[ASTNodeType]int{}}
+	Walk(v, &ast)
+	assert.Equal(4, v.counts[ASTField])
+	assert.Equal(1, v.counts[ASTFunctionExpression])
+	assert.Equal(1, v.counts[ASTComparator])
+	assert.Equal(1, v.counts[ASTLiteral])
+}
+
+func TestASTIsNotOptimized(t *testing.T) {
+	assert := assert.New(t)
+	for _, tt := range []struct {
+		expression string
+		nodeType   ASTNodeType
+	}{
+		{"abs(`-1`)", ASTFunctionExpression},
+		{"length(`[1,2]`) > `1`", ASTComparator},
+		{"foo[?a] | [0]", ASTPipe},
+	} {
+		compiled := MustCompile(tt.expression)
+		assert.Equal(tt.nodeType, compiled.AST().NodeType, tt.expression)
+		assert.NotEqual(tt.nodeType, compiled.OptimizedAST().NodeType, tt.expression)
+	}
+}
+
+func TestInspectCollectsFieldsAndFunctions(t *testing.T) {
+	assert := assert.New(t)
+	ast := MustCompile("users[?contains(roles, 'admin')].{name: name, mail: contact.email}").AST()
+	var fields, functions []string
+	Inspect(&ast, func(node *ASTNode) bool {
+		if node == nil {
+			return false
+		}
+		switch node.NodeType {
+		case ASTField:
+			fields = append(fields, node.Value.(string))
+		case ASTFunctionExpression:
+			functions = append(functions, node.Value.(string))
+		}
+		return true
+	})
+	// The condition is the last child of a filter projection.
+	assert.Equal([]string{"users", "name", "contact", "email", "roles"}, fields)
+	assert.Equal([]string{"contains"}, functions)
+}
+
+func TestInspectPrunes(t *testing.T) {
+	assert := assert.New(t)
+	ast := MustCompile("a.b | sort_by(@, &c.d)").AST()
+	var fields []string
+	Inspect(&ast, func(node *ASTNode) bool {
+		if node == nil || node.NodeType == ASTExpRef {
+			return false
+		}
+		if node.NodeType == ASTField {
+			fields = append(fields, node.Value.(string))
+		}
+		return true
+	})
+	assert.Equal([]string{"a", "b"}, fields)
+}
+
+func TestASTIsACopy(t *testing.T) {
+	assert := assert.New(t)
+	compiled := MustCompile("foo.bar")
+	ast := compiled.AST()
+	ast.Children[0].Value = "changed"
+	result, err := compiled.Search(map[string]interface{}{"foo": map[string]interface{}{"bar": 1.0}})
+	assert.Nil(err)
+	assert.Equal(1.0, result)
+}
+
+func TestASTSpans(t *testing.T) {
+	assert := assert.New(t)
+	expression := "foo[?bar == 'x'] | length( `[1, 2]` ) || {a: b.c, \"d\": [0]}"
+	ast, err := NewParser().Parse(expression)
+	assert.Nil(err)
+	var spans []string
+	Inspect(&ast, func(node *ASTNode) bool {
+		if node != nil && node.Span != (Span{}) {
+			spans = append(spans, node.NodeType.String()+" "+expression[node.Span.Start:node.Span.End])
+		}
+		return true
+	})
+	assert.Equal([]string{
+		"ASTPipe foo[?bar == 'x'] | length( `[1, 2]` ) || {a: b.c, \"d\": [0]}",
+		"ASTFilterProjection foo[?bar == 'x']",
+		"ASTField foo",
+		"ASTComparator bar == 'x'",
+		"ASTField bar",
+		"ASTLiteral 'x'",
+		"ASTOrExpression length( `[1, 2]` ) || {a: b.c, \"d\": [0]}",
+		"ASTFunctionExpression length( `[1, 2]` )",
+		"ASTLiteral `[1, 2]`",
+		"ASTMultiSelectHash {a: b.c, \"d\": [0]}",
+		"ASTKeyValPair a: b.c",
+		"ASTSubexpression b.c",
+		"ASTField b",
+		"ASTField c",
+		"ASTKeyValPair \"d\": [0]",
+		"ASTIndexExpression [0]",
+		"ASTIndex [0]",
+	}, spans)
+}
+
+func TestASTNodeOperator(t *testing.T) {
+	assert := assert.New(t)
+	ast := MustCompile("a >= `1`").AST()
+	assert.Equal(">=", ast.Operator())
+	ast = MustCompile("-a // b").AST()
+	assert.Equal("//", ast.Operator())
+	assert.Equal("-", ast.Children[0].Operator())
+	assert.Equal("", ast.Children[1].Operator())
+}